@@ -118,6 +118,38 @@ func (n *NetGraph) DropIndexOnNetNodeID() error {
 	return err
 }
 
+func (n *NetGraph) CreateIndexOnEndpointMac() error {
+	statement := `CREATE INDEX ON:ENDPOINT(mac)`
+
+	_, err := n.session.Run(statement, nil)
+
+	return err
+}
+
+func (n *NetGraph) DropIndexOnEndpointMac() error {
+	statement := `DROP INDEX ON:ENDPOINT(mac)`
+
+	_, err := n.session.Run(statement, nil)
+
+	return err
+}
+
+func (n *NetGraph) CreateIndexOnSubnetPrefix() error {
+	statement := `CREATE INDEX ON:SUBNET(prefix)`
+
+	_, err := n.session.Run(statement, nil)
+
+	return err
+}
+
+func (n *NetGraph) DropIndexOnSubnetPrefix() error {
+	statement := `DROP INDEX ON:SUBNET(prefix)`
+
+	_, err := n.session.Run(statement, nil)
+
+	return err
+}
+
 func (n *NetGraph) CreateNetNode(node *NetNode) error {
 	params := map[string]interface{}{
 		"id":      node.Id,
@@ -168,16 +200,104 @@ func (n *NetGraph) CreateNetNodeWithTx(node *NetNode) error {
 	return err
 }
 
-func (n *NetGraph) CreateNetLinkByID(startid, endid int64, localports, remoteports []string) error {
+/*
+* 扫描完成后更新节点上由扫描得到的属性
+ */
+func (n *NetGraph) UpdateNetNodeWithTx(node *NetNode) error {
+	params := map[string]interface{}{
+		"id": node.Id,
+		"props": map[string]interface{}{
+			"credential":    node.Credential,
+			"profile":       node.Profile,
+			"sysdescr":      node.SysDescr,
+			"sysoid":        node.SysObjectID,
+			"sysname":       node.SysName,
+			"sysuptime":     node.SysUpTime,
+			"syslocation":   node.SysLocation,
+			"sysvendor":     node.SysVendor,
+			"discrepancies": node.Discrepancies,
+		},
+	}
+
+	_, err := n.tx.Run(`MATCH(n:SWITCH{id:$id}) SET n += $props`, params)
+
+	return err
+}
+
+/*
+* 创建设备的硬件节点：(:SWITCH)-[:HAS_MODULE]->(:MODULE)
+ */
+func (n *NetGraph) CreateNetModulesWithTx(node *NetNode) error {
+	modules := make([]map[string]interface{}, 0, len(node.Modules))
+	for _, module := range node.Modules {
+		modules = append(modules, map[string]interface{}{
+			"index":    module.Index,
+			"class":    module.Class,
+			"name":     module.Name,
+			"descr":    module.Descr,
+			"model":    module.Model,
+			"serial":   module.Serial,
+			"hardware": module.HardwareRev,
+			"firmware": module.FirmwareRev,
+			"software": module.SoftwareRev,
+			"slot":     module.Slot,
+		})
+	}
+	params := map[string]interface{}{
+		"id":      node.Id,
+		"modules": modules,
+	}
+
+	_, err := n.tx.Run(
+		`MATCH(s:SWITCH{id:$id}) UNWIND $modules AS m CREATE(s)-[:HAS_MODULE]->(n:MODULE) SET n = m`, params)
+
+	return err
+}
+
+/*
+* 创建交换机边缘端口上的终端：(:ENDPOINT)-[:ATTACHED_TO]->(:SWITCH)
+* arp为所有设备的ARP表合并后MAC地址到IP地址的映射
+ */
+func (n *NetGraph) CreateEndpointsWithTx(node *NetNode, arp map[string][]string) error {
+	endpoints := make([]map[string]interface{}, 0, len(node.Endpoints))
+	for _, endpoint := range node.Endpoints {
+		ips := arp[endpoint.Mac]
+		if ips == nil {
+			ips = []string{}
+		}
+		endpoints = append(endpoints, map[string]interface{}{
+			"mac":   endpoint.Mac,
+			"ips":   ips,
+			"vlan":  endpoint.Vlan,
+			"vlans": endpoint.Vlans,
+			"fdbid": endpoint.FdbId,
+			"port":  endpoint.Port,
+		})
+	}
+	params := map[string]interface{}{
+		"id":        node.Id,
+		"endpoints": endpoints,
+	}
+
+	_, err := n.tx.Run(
+		`MATCH(s:SWITCH{id:$id}) UNWIND $endpoints AS ep `+
+			`MERGE(e:ENDPOINT{mac:ep.mac}) SET e.ips=ep.ips `+
+			`CREATE(e)-[:ATTACHED_TO{port:ep.port, vlan:ep.vlan, vlans:ep.vlans, fdbid:ep.fdbid}]->(s)`, params)
+
+	return err
+}
+
+func (n *NetGraph) CreateNetLinkByID(startid, endid int64, localports, remoteports []string, props map[string]interface{}) error {
 	params := map[string]interface{}{
 		"start":  startid,
 		"end":    endid,
 		"lports": localports,
 		"rports": remoteports,
+		"props":  props,
 	}
 
 	_, err := n.session.Run(
-		`MATCH(s), (e) WHERE id(s)=$start and id(e)=$end CREATE(s)-[:LINK_TO{lports:$lports, rports:$rports}]->(e)`, params)
+		`MATCH(s), (e) WHERE id(s)=$start and id(e)=$end CREATE(s)-[r:LINK_TO{lports:$lports, rports:$rports}]->(e) SET r += $props`, params)
 
 	if err != nil {
 		return err
@@ -186,16 +306,17 @@ func (n *NetGraph) CreateNetLinkByID(startid, endid int64, localports, remotepor
 	return nil
 }
 
-func (n *NetGraph) CreateNetLinkByIDWithTX(startid, endid int64, localports, remoteports []string) error {
+func (n *NetGraph) CreateNetLinkByIDWithTX(startid, endid int64, localports, remoteports []string, props map[string]interface{}) error {
 	params := map[string]interface{}{
 		"start":  startid,
 		"end":    endid,
 		"lports": localports,
 		"rports": remoteports,
+		"props":  props,
 	}
 
 	_, err := n.tx.Run(
-		`MATCH(s), (e) WHERE id(s)=$start and id(e)=$end CREATE(s)-[:LINK_TO{lports:$lports, rports:$rports}]->(e)`, params)
+		`MATCH(s), (e) WHERE id(s)=$start and id(e)=$end CREATE(s)-[r:LINK_TO{lports:$lports, rports:$rports}]->(e) SET r += $props`, params)
 
 	if err != nil {
 		return err
@@ -204,16 +325,17 @@ func (n *NetGraph) CreateNetLinkByIDWithTX(startid, endid int64, localports, rem
 	return nil
 }
 
-func (n *NetGraph) CreateNetLinkByNetNodeID(startid, endid int64, localports, remoteports []string) error {
+func (n *NetGraph) CreateNetLinkByNetNodeID(startid, endid int64, localports, remoteports []string, props map[string]interface{}) error {
 	params := map[string]interface{}{
 		"start":  startid,
 		"end":    endid,
 		"lports": localports,
 		"rports": remoteports,
+		"props":  props,
 	}
 
 	_, err := n.session.Run(
-		`MATCH(s:SWITCH{id:$start}), (e:SWITCH{id:$end}) CREATE(s)-[:LINK_TO{lports:$lports, rports:$rports}]->(e)`, params)
+		`MATCH(s:SWITCH{id:$start}), (e:SWITCH{id:$end}) CREATE(s)-[r:LINK_TO{lports:$lports, rports:$rports}]->(e) SET r += $props`, params)
 
 	if err != nil {
 		return err
@@ -222,16 +344,38 @@ func (n *NetGraph) CreateNetLinkByNetNodeID(startid, endid int64, localports, re
 	return nil
 }
 
-func (n *NetGraph) CreateNetLinkByNetNodeIDWithTX(startid, endid int64, localports, remoteports []string) error {
+func (n *NetGraph) CreateNetLinkByNetNodeIDWithTX(startid, endid int64, localports, remoteports []string, props map[string]interface{}) error {
 	params := map[string]interface{}{
 		"start":  startid,
 		"end":    endid,
 		"lports": localports,
 		"rports": remoteports,
+		"props":  props,
+	}
+
+	_, err := n.tx.Run(
+		`MATCH(s:SWITCH{id:$start}), (e:SWITCH{id:$end}) CREATE(s)-[r:LINK_TO{lports:$lports, rports:$rports}]->(e) SET r += $props`, params)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+* 更新已创建的LINK_TO关系，两个节点之间可能有多条关系，使用本端端口区分
+ */
+func (n *NetGraph) UpdateNetLinkByNetNodeIDWithTX(startid, endid int64, localports []string, props map[string]interface{}) error {
+	params := map[string]interface{}{
+		"start":  startid,
+		"end":    endid,
+		"lports": localports,
+		"props":  props,
 	}
 
 	_, err := n.tx.Run(
-		`MATCH(s:SWITCH{id:$start}), (e:SWITCH{id:$end}) CREATE(s)-[:LINK_TO{lports:$lports, rports:$rports}]->(e)`, params)
+		`MATCH(s:SWITCH{id:$start})-[r:LINK_TO]->(e:SWITCH{id:$end}) WHERE r.lports=$lports SET r += $props`, params)
 
 	if err != nil {
 		return err
@@ -240,6 +384,93 @@ func (n *NetGraph) CreateNetLinkByNetNodeIDWithTX(startid, endid int64, localpor
 	return nil
 }
 
+func (n *NetGraph) CreateBGPPeerByNetNodeIDWithTX(startid, endid int64, props map[string]interface{}) error {
+	params := map[string]interface{}{
+		"start": startid,
+		"end":   endid,
+		"props": props,
+	}
+
+	_, err := n.tx.Run(
+		`MATCH(s:SWITCH{id:$start}), (e:SWITCH{id:$end}) CREATE(s)-[r:BGP_PEER]->(e) SET r = $props`, params)
+
+	return err
+}
+
+/*
+* 对端不属于任何已扫描设备的BGP会话，对端为(:BGP_PEER_ADDR{addr})节点
+ */
+func (n *NetGraph) CreateUnresolvedBGPPeerWithTX(startid int64, props map[string]interface{}) error {
+	params := map[string]interface{}{
+		"start": startid,
+		"addr":  props["raddr"],
+		"props": props,
+	}
+
+	_, err := n.tx.Run(
+		`MATCH(s:SWITCH{id:$start}) MERGE(e:BGP_PEER_ADDR{addr:$addr}) CREATE(s)-[r:BGP_PEER]->(e) SET r = $props`, params)
+
+	return err
+}
+
+/*
+* 创建SUBNET节点，members中每个元素包含id、port、ip
+ */
+func (n *NetGraph) CreateSubnetWithTX(prefix string, version int, p2p bool, members []map[string]interface{}) error {
+	params := map[string]interface{}{
+		"prefix":  prefix,
+		"version": version,
+		"p2p":     p2p,
+		"members": members,
+	}
+
+	_, err := n.tx.Run(
+		`MERGE(p:SUBNET{prefix:$prefix}) SET p.version=$version, p.p2p=$p2p `+
+			`WITH p UNWIND $members AS m MATCH(s:SWITCH{id:m.id}) `+
+			`CREATE(s)-[:IN_SUBNET{port:m.port, ip:m.ip}]->(p)`, params)
+
+	return err
+}
+
+func (n *NetGraph) CreateL3LinkByNetNodeIDWithTX(startid, endid int64, props map[string]interface{}) error {
+	params := map[string]interface{}{
+		"start": startid,
+		"end":   endid,
+		"props": props,
+	}
+
+	_, err := n.tx.Run(
+		`MATCH(s:SWITCH{id:$start}), (e:SWITCH{id:$end}) CREATE(s)-[r:L3_LINK]->(e) SET r = $props`, params)
+
+	return err
+}
+
+func (n *NetGraph) CreateNextHopByNetNodeIDWithTX(startid, endid int64, props map[string]interface{}) error {
+	params := map[string]interface{}{
+		"start": startid,
+		"end":   endid,
+		"props": props,
+	}
+
+	_, err := n.tx.Run(
+		`MATCH(s:SWITCH{id:$start}), (e:SWITCH{id:$end}) CREATE(s)-[r:NEXT_HOP]->(e) SET r = $props`, params)
+
+	return err
+}
+
+func (n *NetGraph) CreateIGPAdjByNetNodeIDWithTX(startid, endid int64, props map[string]interface{}) error {
+	params := map[string]interface{}{
+		"start": startid,
+		"end":   endid,
+		"props": props,
+	}
+
+	_, err := n.tx.Run(
+		`MATCH(s:SWITCH{id:$start}), (e:SWITCH{id:$end}) CREATE(s)-[r:IGP_ADJ]->(e) SET r = $props`, params)
+
+	return err
+}
+
 func (n *NetGraph) QueryNetNode(props map[string]interface{}) ([]neo4j.Node, error) {
 	/*
 	* Make sure the key of map is same as the NetNode's property name.
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"graph"
 	"log"
-	_ "mock"
 	"os"
 	. "scanner"
 	"sync"
+	"time"
 	"util"
 )
 
@@ -18,6 +20,8 @@ func SaveNetNodes(netgraph *graph.NetGraph, netnodes []*util.NetNode) (map[strin
 	}
 
 	_ = netgraph.DropIndexOnNetNodeID()
+	_ = netgraph.DropIndexOnEndpointMac()
+	_ = netgraph.DropIndexOnSubnetPrefix()
 
 	err = netgraph.TxStart()
 	if err != nil {
@@ -51,8 +55,368 @@ func SaveNetNodes(netgraph *graph.NetGraph, netnodes []*util.NetNode) (map[strin
 		return nil, err
 	}
 
+	err = netgraph.CreateIndexOnEndpointMac()
+	if err != nil {
+		return nil, err
+	}
+
+	err = netgraph.CreateIndexOnSubnetPrefix()
+	if err != nil {
+		return nil, err
+	}
+
 	return nodeids, nil
 }
+
+func UpdateNetNodes(netgraph *graph.NetGraph, netnodes []*util.NetNode) error {
+	err := netgraph.TxStart()
+	if err != nil {
+		return err
+	}
+
+	for _, node := range netnodes {
+		err = netgraph.UpdateNetNodeWithTx(node)
+		if err != nil {
+			_ = netgraph.TxRollback()
+			return err
+		}
+		if len(node.Modules) == 0 {
+			continue
+		}
+		err = netgraph.CreateNetModulesWithTx(node)
+		if err != nil {
+			_ = netgraph.TxRollback()
+			return err
+		}
+	}
+
+	err = netgraph.TxCommit()
+	if err != nil {
+		_ = netgraph.TxRollback()
+		return err
+	}
+
+	return netgraph.TxClose()
+}
+
+/*
+* 合并所有设备的ARP表，创建接入在交换机边缘端口上的终端
+ */
+func SaveEndpoints(netgraph *graph.NetGraph, netnodes []*util.NetNode) error {
+	arp := map[string][]string{}
+	for _, node := range netnodes {
+		for mac, ips := range node.Arp {
+			for _, ip := range ips {
+				exist := false
+				for _, v := range arp[mac] {
+					if v == ip {
+						exist = true
+						break
+					}
+				}
+				if !exist {
+					arp[mac] = append(arp[mac], ip)
+				}
+			}
+		}
+	}
+
+	err := netgraph.TxStart()
+	if err != nil {
+		return err
+	}
+
+	for _, node := range netnodes {
+		if len(node.Endpoints) == 0 {
+			continue
+		}
+		err = netgraph.CreateEndpointsWithTx(node, arp)
+		if err != nil {
+			_ = netgraph.TxRollback()
+			return err
+		}
+	}
+
+	err = netgraph.TxCommit()
+	if err != nil {
+		_ = netgraph.TxRollback()
+		return err
+	}
+
+	return netgraph.TxClose()
+}
+
+/*
+* 根据BGP会话的对端地址找到对端设备，创建BGP_PEER关系，找不到对端的会话连接到对端地址
+ */
+func SaveBGPPeers(netgraph *graph.NetGraph, netnodes []*util.NetNode, nodeids map[string]int64) error {
+	addresses := AddressIndex(netnodes)
+
+	err := netgraph.TxStart()
+	if err != nil {
+		return err
+	}
+
+	for _, node := range netnodes {
+		for _, peer := range node.BGPPeers {
+			remote, ok := addresses[peer.RemoteAddr]
+			if !ok && SpecifiedAddress(peer.RemoteID) {
+				remote, ok = addresses[peer.RemoteID]
+			}
+			if ok && remote == node.Mgt {
+				continue
+			}
+			props := map[string]interface{}{
+				"laddr":    peer.LocalAddr,
+				"las":      peer.LocalAs,
+				"raddr":    peer.RemoteAddr,
+				"ras":      peer.RemoteAs,
+				"rid":      peer.RemoteID,
+				"state":    peer.State,
+				"uptime":   peer.Uptime,
+				"resolved": ok,
+			}
+			// 对端不属于已扫描的设备时连接到对端地址，不猜测对端设备
+			if ok {
+				err = netgraph.CreateBGPPeerByNetNodeIDWithTX(nodeids[node.Mgt], nodeids[remote], props)
+			} else {
+				util.Logger.Printf("[%s]Unresolved BGP peer %s AS%d %s\n", node.Mgt, peer.RemoteAddr, peer.RemoteAs, peer.State)
+				err = netgraph.CreateUnresolvedBGPPeerWithTX(nodeids[node.Mgt], props)
+			}
+			if err != nil {
+				_ = netgraph.TxRollback()
+				return err
+			}
+		}
+	}
+
+	err = netgraph.TxCommit()
+	if err != nil {
+		_ = netgraph.TxRollback()
+		return err
+	}
+
+	return netgraph.TxClose()
+}
+
+/*
+* 创建SUBNET节点，两端分别在不同设备上的点到点网段同时创建L3_LINK关系
+ */
+func SaveSubnets(netgraph *graph.NetGraph, netnodes []*util.NetNode, nodeids map[string]int64) error {
+	err := netgraph.TxStart()
+	if err != nil {
+		return err
+	}
+
+	for _, subnet := range Subnets(netnodes) {
+		members := make([]map[string]interface{}, 0, len(subnet.Members))
+		for _, m := range subnet.Members {
+			members = append(members, map[string]interface{}{
+				"id":   nodeids[m.Mgt],
+				"port": m.Port,
+				"ip":   m.IP,
+			})
+		}
+		err = netgraph.CreateSubnetWithTX(subnet.Prefix, subnet.Version, subnet.P2P, members)
+		if err != nil {
+			_ = netgraph.TxRollback()
+			return err
+		}
+
+		if !subnet.P2P || len(subnet.Members) != 2 || subnet.Members[0].Mgt == subnet.Members[1].Mgt {
+			continue
+		}
+		local, remote := subnet.Members[0], subnet.Members[1]
+		err = netgraph.CreateL3LinkByNetNodeIDWithTX(nodeids[local.Mgt], nodeids[remote.Mgt], map[string]interface{}{
+			"subnet": subnet.Prefix,
+			"lport":  local.Port,
+			"lip":    local.IP,
+			"rport":  remote.Port,
+			"rip":    remote.IP,
+		})
+		if err != nil {
+			_ = netgraph.TxRollback()
+			return err
+		}
+	}
+
+	err = netgraph.TxCommit()
+	if err != nil {
+		_ = netgraph.TxRollback()
+		return err
+	}
+
+	return netgraph.TxClose()
+}
+
+/*
+* 按对端设备创建NEXT_HOP关系，对端不是物理相邻设备时记录日志
+ */
+func SaveNextHops(netgraph *graph.NetGraph, netnodes []*util.NetNode, nodeids map[string]int64, links []*NetNeighbor) error {
+	err := netgraph.TxStart()
+	if err != nil {
+		return err
+	}
+
+	for _, group := range NextHops(netnodes, links) {
+		if !group.Adjacent {
+			util.Logger.Printf("[%s]Next hop %s is not adjacent, %d prefixes\n", group.Local, group.Remote, len(group.Prefixes))
+		}
+		err = netgraph.CreateNextHopByNetNodeIDWithTX(nodeids[group.Local], nodeids[group.Remote], map[string]interface{}{
+			"prefixes": group.Prefixes,
+			"nexthops": group.NextHops,
+			"ports":    group.Ports,
+			"adjacent": group.Adjacent,
+		})
+		if err != nil {
+			_ = netgraph.TxRollback()
+			return err
+		}
+	}
+
+	err = netgraph.TxCommit()
+	if err != nil {
+		_ = netgraph.TxRollback()
+		return err
+	}
+
+	return netgraph.TxClose()
+}
+
+/*
+* 打印从source设备到dest的逐跳转发路径，只有采集了路由表的设备(routeroles)能够继续查找下一跳
+ */
+func PrintTracePath(netnodes []*util.NetNode, source, dest string) error {
+	hops, err := TracePath(netnodes, source, dest)
+	for i, hop := range hops {
+		switch {
+		case hop.Route == nil:
+			fmt.Printf("%d %s no route to %s\n", i+1, hop.Mgt, dest)
+		case hop.Route.NextHop == "":
+			fmt.Printf("%d %s %s direct %s (%s)\n", i+1, hop.Mgt, hop.Route.Prefix, hop.Route.Port, hop.Route.Proto)
+		default:
+			fmt.Printf("%d %s %s via %s %s (%s)\n", i+1, hop.Mgt, hop.Route.Prefix, hop.Route.NextHop, hop.Route.Port, hop.Route.Proto)
+		}
+	}
+	return err
+}
+
+/*
+* 将链路速率写入LINK_TO关系
+ */
+func SaveLinkRates(netgraph *graph.NetGraph, nodeids map[string]int64, rates map[*NetNeighbor]map[string]interface{}) error {
+	err := netgraph.TxStart()
+	if err != nil {
+		return err
+	}
+
+	for link, props := range rates {
+		err = netgraph.UpdateNetLinkByNetNodeIDWithTX(
+			nodeids[link.LocalIP],
+			nodeids[link.RemoteIP],
+			link.LocalPort,
+			props)
+		if err != nil {
+			_ = netgraph.TxRollback()
+			return err
+		}
+	}
+
+	err = netgraph.TxCommit()
+	if err != nil {
+		_ = netgraph.TxRollback()
+		return err
+	}
+
+	return netgraph.TxClose()
+}
+
+/*
+* 根据OSPF router id、IS-IS system id或邻居地址找到对端设备，创建IGP_ADJ关系
+ */
+func SaveIGPAdjs(netgraph *graph.NetGraph, netnodes []*util.NetNode, nodeids map[string]int64) error {
+	addresses := AddressIndex(netnodes)
+
+	err := netgraph.TxStart()
+	if err != nil {
+		return err
+	}
+
+	for _, node := range netnodes {
+		for _, adj := range node.IGPAdjs {
+			remote, ok := addresses[adj.NeighborID]
+			if !ok && adj.NeighborAddr != "" {
+				remote, ok = addresses[adj.NeighborAddr]
+			}
+			if !ok || remote == node.Mgt {
+				util.Logger.Printf("[%s]Unresolved %s neighbor %s %s\n", node.Mgt, adj.Protocol, adj.NeighborID, adj.State)
+				continue
+			}
+			err = netgraph.CreateIGPAdjByNetNodeIDWithTX(nodeids[node.Mgt], nodeids[remote], map[string]interface{}{
+				"proto":  adj.Protocol,
+				"port":   adj.LocalPort,
+				"naddr":  adj.NeighborAddr,
+				"nid":    adj.NeighborID,
+				"area":   adj.Area,
+				"level":  adj.Level,
+				"metric": adj.Metric,
+				"state":  adj.State,
+			})
+			if err != nil {
+				_ = netgraph.TxRollback()
+				return err
+			}
+		}
+	}
+
+	err = netgraph.TxCommit()
+	if err != nil {
+		_ = netgraph.TxRollback()
+		return err
+	}
+
+	return netgraph.TxClose()
+}
+
+/*
+* 所有设备扫描完成后，补充LINK_TO关系上对端接口的信息
+ */
+func UpdateNetLinks(netgraph *graph.NetGraph, netnodes []*util.NetNode, nodeids map[string]int64, links []*NetNeighbor) error {
+	nodes := make(map[string]*util.NetNode, len(netnodes))
+	for _, node := range netnodes {
+		nodes[node.Mgt] = node
+	}
+
+	err := netgraph.TxStart()
+	if err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		remote, ok := nodes[link.RemoteIP]
+		if !ok || remote.Interfaces == nil {
+			continue
+		}
+		err = netgraph.UpdateNetLinkByNetNodeIDWithTX(
+			nodeids[link.LocalIP],
+			nodeids[link.RemoteIP],
+			link.LocalPort,
+			link.RemoteProps(remote))
+		if err != nil {
+			_ = netgraph.TxRollback()
+			return err
+		}
+	}
+
+	err = netgraph.TxCommit()
+	if err != nil {
+		_ = netgraph.TxRollback()
+		return err
+	}
+
+	return netgraph.TxClose()
+}
+
 func main() {
 
 	const (
@@ -60,17 +424,28 @@ func main() {
 		MaxNetChassisIdNum        = 30000
 		MaxUnValidNeighborChanNum = 20000
 		MaxValidNeighborChanNum   = 10000
-		Community                 = "360buy"
 	)
 
+	configfile := flag.String("config", "./config.json", "the config file")
+	replaydir := flag.String("replay", "", "replay snmpwalk/snmprec captures in the directory instead of scanning the network")
+	recorddir := flag.String("record", "", "record the snmp responses of every device into the directory")
+	tracefrom := flag.String("tracefrom", "", "print the forwarding path from the device(management ip) after scanning")
+	traceto := flag.String("traceto", "", "the destination address or prefix of -tracefrom")
+	flag.Parse()
+
 	//the config
-	const configfile = "./config.json"
-	config, err := util.NewConfig(configfile)
+	config, err := util.NewConfig(*configfile)
 	if err != nil {
 		log.Printf("Failed to get configuration infomations. %v\n", err)
 		os.Exit(1)
 	}
 
+	credentials, err := util.NewCredentialStore(config)
+	if err != nil {
+		log.Printf("Failed to load credential profiles. %v\n", err)
+		os.Exit(1)
+	}
+
 	// the batch commit number
 	var CommitBatch = config.SaveBatch
 
@@ -84,10 +459,49 @@ func main() {
 
 	util.Logger = log.New(logbufer, "[INFO]", log.LstdFlags)
 
-	netnodes, err := GetNetNode(config.Url)
-	if err != nil {
-		util.Logger.Printf("Failed to get netnode infomations. %v\n", err)
-		os.Exit(1)
+	// 记录爬取和扫描时设备返回的所有PDU，回放时不需要记录
+	dialer := &LiveDialer{Credentials: credentials}
+	if *recorddir != "" && *replaydir == "" {
+		dialer.Recorder, err = NewRecorder(*recorddir)
+		if err != nil {
+			util.Logger.Printf("Failed to record to %s. %v\n", *recorddir, err)
+			os.Exit(1)
+		}
+	}
+	recorder := dialer.Recorder
+	collectors := DefaultCollectors(credentials, dialer, config.RouteRoles)
+
+	var netnodes []*util.NetNode
+	if *replaydir != "" {
+		source, err := NewReplaySource(*replaydir)
+		if err != nil {
+			util.Logger.Printf("Failed to load captures. %v\n", err)
+			os.Exit(1)
+		}
+		netnodes = source.Nodes()
+		collectors = []Collector{&SNMPCollector{Dialer: source, RouteRoles: config.RouteRoles}}
+		util.Logger.Printf("Replay %d netnodes from %s.\n", len(netnodes), *replaydir)
+	} else if len(config.Crawl.Seeds) > 0 {
+		crawler, err := NewCrawler(&config.Crawl, dialer)
+		if err != nil {
+			util.Logger.Printf("Failed to init crawler. %v\n", err)
+			os.Exit(1)
+		}
+		netnodes = crawler.Crawl()
+		util.Logger.Printf("Crawled %d netnodes.\n", len(netnodes))
+	} else {
+		netnodes, err = GetNetNode(config.Url)
+		if err != nil {
+			util.Logger.Printf("Failed to get netnode infomations. %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if recorder != nil {
+		if err := recorder.SaveNodes(netnodes); err != nil {
+			util.Logger.Printf("Failed to record netnodes. %v\n", err)
+			os.Exit(1)
+		}
 	}
 
 	boltserver := config.NeoServer
@@ -115,7 +529,7 @@ func main() {
 		UnValidNeighborChan: make(chan *NetNeighbor, MaxUnValidNeighborChanNum),
 		UnValidNeighbor:     NodeListInit(),
 		ValidNeighborChan:   make(chan *NetNeighbor, MaxValidNeighborChanNum),
-		Community:           Community,
+		Collectors:          collectors,
 		ScanFinished:        false,
 		SaveFinished:        sync.WaitGroup{},
 		SavedCount:          0,
@@ -133,12 +547,19 @@ func main() {
 		os.Exit(1)
 	}
 
+	// 已保存的链路，用于扫描完成后补充对端信息
+	links := make([]*NetNeighbor, 0, MaxValidNeighborChanNum)
+
 	saveneighbor := func(neighbor *NetNeighbor) error {
 		err := netgraph.CreateNetLinkByNetNodeIDWithTX(
 			nodeids[neighbor.LocalIP],
 			nodeids[neighbor.RemoteIP],
 			neighbor.LocalPort,
-			neighbor.RemotePort)
+			neighbor.RemotePort,
+			neighbor.Props())
+		if err == nil {
+			links = append(links, neighbor)
+		}
 		if worker.SavedCount > CommitBatch {
 			err = netgraph.TxCommit()
 			if err != nil {
@@ -167,6 +588,11 @@ func main() {
 
 	worker.SaveFinished.Wait()
 
+	// 扫描已经结束，之后的采集不再记录
+	if recorder != nil {
+		recorder.Stop()
+	}
+
 	err = netgraph.TxCommit()
 	if err != nil {
 		_ = netgraph.TxRollback()
@@ -180,5 +606,62 @@ func main() {
 
 	}
 
+	err = UpdateNetNodes(netgraph, netnodes)
+	if err != nil {
+		util.Logger.Printf("Update Nodes Failed. %v\n", err)
+	}
+
+	err = UpdateNetLinks(netgraph, netnodes, nodeids, links)
+	if err != nil {
+		util.Logger.Printf("Update Links Failed. %v\n", err)
+	}
+
+	err = SaveEndpoints(netgraph, netnodes)
+	if err != nil {
+		util.Logger.Printf("Save Endpoints Failed. %v\n", err)
+	}
+
+	err = SaveBGPPeers(netgraph, netnodes, nodeids)
+	if err != nil {
+		util.Logger.Printf("Save BGP Peers Failed. %v\n", err)
+	}
+
+	err = SaveIGPAdjs(netgraph, netnodes, nodeids)
+	if err != nil {
+		util.Logger.Printf("Save IGP Adjacencies Failed. %v\n", err)
+	}
+
+	err = SaveSubnets(netgraph, netnodes, nodeids)
+	if err != nil {
+		util.Logger.Printf("Save Subnets Failed. %v\n", err)
+	}
+
+	err = SaveNextHops(netgraph, netnodes, nodeids, links)
+	if err != nil {
+		util.Logger.Printf("Save Next Hops Failed. %v\n", err)
+	}
+
 	util.Logger.Printf("Scan Completed!")
+
+	if *tracefrom != "" && *traceto != "" {
+		if err := PrintTracePath(netnodes, *tracefrom, *traceto); err != nil {
+			util.Logger.Printf("Trace %s to %s Failed. %v\n", *tracefrom, *traceto, err)
+			fmt.Printf("%v\n", err)
+		}
+	}
+
+	// 回放的数据没有变化，不需要采集速率
+	if config.PollInterval <= 0 || *replaydir != "" {
+		return
+	}
+
+	poller := NewCounterPoller(netnodes, links, credentials)
+	for {
+		rates := poller.Poll()
+		err = SaveLinkRates(netgraph, nodeids, rates)
+		if err != nil {
+			util.Logger.Printf("Save Link Rates Failed. %v\n", err)
+		}
+		time.Sleep(time.Duration(config.PollInterval) * time.Second)
+	}
 }
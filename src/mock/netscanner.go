@@ -1,17 +1,10 @@
 package mock
 
 import (
+	. "scanner"
 	. "util"
 )
 
-const (
-	lldpLocChassisID      = "1.0.8802.1.1.2.1.3.2.0"
-	lldpLocChassisIDNexus = "1.3.6.1.2.1.2.2.1.6"
-	lldpRemChassisID      = "1.0.8802.1.1.2.1.4.1.1.5"
-	lldpRemPortID         = "1.0.8802.1.1.2.1.4.1.1.7"
-	lldpLocPortID         = "1.0.8802.1.1.2.1.3.7.1.3"
-)
-
 type Info struct {
 	Mgt             string
 	ChassisID       string
@@ -45,45 +38,37 @@ var nwsw = map[string]Info{
 	},
 }
 
-type NetNodeHandler struct {
-	node *NetNode
-}
-
-func NewNetNodeHandler(netnode *NetNode, community string) *NetNodeHandler {
-	return &NetNodeHandler{
-		node: netnode,
-	}
-}
-
-func (n *NetNodeHandler) SNMPConnect() error {
-	return nil
-}
+/*
+* 使用nwsw中的固定数据代替设备的采集方式
+ */
+type FakeCollector struct{}
 
-func (n *NetNodeHandler) SNMPClose() error {
-	return nil
+func (c *FakeCollector) Name() string {
+	return "mock"
 }
 
-func (n *NetNodeHandler) SelfChassisID() ([]string, error) {
-	var result = []string{}
-	result = append(result, nwsw[n.node.Mgt].ChassisID)
-	return result, nil
+func (c *FakeCollector) Match(netnode *NetNode) bool {
+	_, ok := nwsw[netnode.Mgt]
+	return ok
 }
-func (n *NetNodeHandler) RemChassisID() (map[string]string, error) {
-	result := make(map[string]string)
-
-	result = nwsw[n.node.Mgt].RemoteChassisID
 
-	return result, nil
-}
+func (c *FakeCollector) Collect(netnode *NetNode, register func(id string)) ([]*NetNeighbor, error) {
+	info := nwsw[netnode.Mgt]
+	register(info.ChassisID)
 
-func (n *NetNodeHandler) RemPort() (map[string]string, error) {
-	result := make(map[string]string)
-	result = nwsw[n.node.Mgt].RemotePort
-	return result, nil
-}
+	neighbors := map[string]*NetNeighbor{}
+	for idx, chassis := range info.RemoteChassisID {
+		if _, ok := neighbors[chassis]; !ok {
+			neighbors[chassis] = NewNetNeighbor(netnode)
+			neighbors[chassis].RemoteChassis = chassis
+		}
+		neighbors[chassis].AddPort("lldp", info.LocalPort[idx], info.RemotePort[idx], "ifName")
+	}
 
-func (n *NetNodeHandler) LocalPort() (map[string]string, error) {
-	result := make(map[string]string)
-	result = nwsw[n.node.Mgt].LocalPort
+	result := make([]*NetNeighbor, 0, len(neighbors))
+	for chassis, neighbor := range neighbors {
+		neighbor.AddRemoteID(chassis)
+		result = append(result, neighbor)
+	}
 	return result, nil
 }
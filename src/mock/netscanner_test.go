@@ -0,0 +1,61 @@
+package mock
+
+import (
+	"io/ioutil"
+	"log"
+	. "scanner"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	. "util"
+)
+
+func TestFakeCollector(t *testing.T) {
+	Logger = log.New(ioutil.Discard, "", 0)
+	nodes, _ := GetNetNodeMock("")
+
+	worker := &NetNeighborScanner{
+		NetNodes:            nodes,
+		NetChassisIdChan:    make(chan [2]string, 100),
+		NetChassisId:        NewSafeMap(100),
+		UnValidNeighborChan: make(chan *NetNeighbor, 100),
+		UnValidNeighbor:     NodeListInit(),
+		ValidNeighborChan:   make(chan *NetNeighbor, 100),
+		Collectors:          []Collector{&FakeCollector{}},
+		ScanFinished:        false,
+		SaveFinished:        sync.WaitGroup{},
+		SavedCount:          0,
+	}
+	worker.ReadChannel()
+	worker.GenerateNeighbor()
+
+	// 先扫描的设备的邻居要等对端登记chassis id之后才能解析
+	links := []string{}
+	timeout := time.After(10 * time.Second)
+	for len(links) < 7 {
+		select {
+		case neighbor := <-worker.ValidNeighborChan:
+			ports := append([]string{}, neighbor.LocalPort...)
+			sort.Strings(ports)
+			links = append(links, neighbor.LocalIP+"->"+neighbor.RemoteIP+" "+strings.Join(ports, ","))
+		case <-timeout:
+			t.Fatalf("resolved %d links: %v", len(links), links)
+		}
+	}
+
+	sort.Strings(links)
+	want := []string{
+		"172.0.0.1->172.0.0.1 F1/0/1",
+		"172.0.0.1->172.0.0.2 F1/0/2",
+		"172.0.0.1->172.0.0.3 F1/0/3",
+		"172.0.0.2->172.0.0.1 40GE1/0/1,40GE1/0/2",
+		"172.0.0.2->172.0.0.3 40GE1/0/3",
+		"172.0.0.3->172.0.0.1 40GE1/0/1,40GE1/0/2",
+		"172.0.0.3->172.0.0.3 40GE1/0/3",
+	}
+	if strings.Join(links, "\n") != strings.Join(want, "\n") {
+		t.Errorf("links:\n%s\nwant:\n%s", strings.Join(links, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,163 @@
+package scanner
+
+import (
+	"github.com/gosnmp"
+	"net"
+	"strings"
+	. "util"
+)
+
+const (
+	bgpLocalAs = "1.3.6.1.2.1.15.2.0"
+)
+
+/*
+* BGP邻居表的列，AddrIndex为true时索引为InetAddressType.len.addr，否则为a.b.c.d
+ */
+type BGPPeerTable struct {
+	State           string
+	LocalAddr       string
+	RemoteAs        string
+	RemoteID        string
+	EstablishedTime string
+	AddrIndex       bool
+}
+
+// BGP4-MIB bgpPeerTable，只包含默认VRF的IPv4邻居
+var bgp4PeerTable = &BGPPeerTable{
+	State:           "1.3.6.1.2.1.15.3.1.2",
+	LocalAddr:       "1.3.6.1.2.1.15.3.1.5",
+	RemoteAs:        "1.3.6.1.2.1.15.3.1.9",
+	RemoteID:        "1.3.6.1.2.1.15.3.1.1",
+	EstablishedTime: "1.3.6.1.2.1.15.3.1.16",
+	AddrIndex:       false,
+}
+
+// CISCO-BGP4-MIB cbgpPeer2Table，包含IPv6邻居
+var ciscoBGPPeer2Table = &BGPPeerTable{
+	State:           "1.3.6.1.4.1.9.9.187.1.2.5.1.3",
+	LocalAddr:       "1.3.6.1.4.1.9.9.187.1.2.5.1.6",
+	RemoteAs:        "1.3.6.1.4.1.9.9.187.1.2.5.1.11",
+	RemoteID:        "1.3.6.1.4.1.9.9.187.1.2.5.1.12",
+	EstablishedTime: "1.3.6.1.4.1.9.9.187.1.2.5.1.19",
+	AddrIndex:       true,
+}
+
+var bgpPeerStateName = map[int]string{
+	1: "idle",
+	2: "connect",
+	3: "active",
+	4: "opensent",
+	5: "openconfirm",
+	6: "established",
+}
+
+/*
+* 将IpAddress或InetAddress类型的值转换为字符串
+ */
+func pduAddress(pdu gosnmp.SnmpPDU) string {
+	switch pdu.Type {
+	case gosnmp.IPAddress:
+		return pdu.Value.(string)
+	case gosnmp.OctetString:
+		if addr := pdu.Value.([]byte); len(addr) == net.IPv4len || len(addr) == net.IPv6len {
+			return net.IP(addr).String()
+		}
+	}
+	return ""
+}
+
+func (n *NetNodeHandler) walkAddress(oid string) (map[string]string, error) {
+	result := make(map[string]string)
+	resp, err := n.snmpd.BulkWalkAll(oid)
+	if err != nil {
+		return nil, err
+	}
+	for _, pdu := range resp {
+		if addr := pduAddress(pdu); addr != "" {
+			result[oidIndex(pdu.Name, oid)] = addr
+		}
+	}
+	return result, nil
+}
+
+/*
+* 读取BGP邻居，厂商模板指定了私有MIB时优先使用，没有数据时使用BGP4-MIB
+ */
+func (n *NetNodeHandler) BGPPeers() ([]*NetBGPPeer, error) {
+	localas := int64(0)
+	resp, err := n.snmpd.Get([]string{bgpLocalAs})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Variables) > 0 {
+		localas = int64(pduInt(resp.Variables[0]))
+	}
+
+	tables := []*BGPPeerTable{bgp4PeerTable}
+	if n.profile.BGPPeerTable != nil {
+		tables = []*BGPPeerTable{n.profile.BGPPeerTable, bgp4PeerTable}
+	}
+
+	for _, table := range tables {
+		peers, err := n.bgpPeers(table, localas)
+		if err != nil {
+			return nil, err
+		}
+		if len(peers) > 0 {
+			return peers, nil
+		}
+	}
+	return []*NetBGPPeer{}, nil
+}
+
+func (n *NetNodeHandler) bgpPeers(table *BGPPeerTable, localas int64) ([]*NetBGPPeer, error) {
+	result := []*NetBGPPeer{}
+
+	states, err := n.walkInteger(table.State)
+	if err != nil || len(states) == 0 {
+		return result, err
+	}
+	localaddrs, err := n.walkAddress(table.LocalAddr)
+	if err != nil {
+		return nil, err
+	}
+	remoteas, err := n.walkInteger(table.RemoteAs)
+	if err != nil {
+		return nil, err
+	}
+	remoteids, err := n.walkAddress(table.RemoteID)
+	if err != nil {
+		return nil, err
+	}
+	uptimes, err := n.walkInteger(table.EstablishedTime)
+	if err != nil {
+		return nil, err
+	}
+
+	for index, state := range states {
+		parts := strings.Split(index, ".")
+		if table.AddrIndex {
+			// InetAddressType.len.addr
+			if len(parts) < 3 {
+				continue
+			}
+			parts = parts[2:]
+		}
+		addr := oidBytes(parts)
+		if len(addr) != net.IPv4len && len(addr) != net.IPv6len {
+			continue
+		}
+
+		result = append(result, &NetBGPPeer{
+			LocalAddr:  localaddrs[index],
+			LocalAs:    localas,
+			RemoteAddr: net.IP(addr).String(),
+			RemoteAs:   int64(uint32(remoteas[index])),
+			RemoteID:   remoteids[index],
+			State:      bgpPeerStateName[state],
+			Uptime:     int64(uptimes[index]),
+		})
+	}
+	return result, nil
+}
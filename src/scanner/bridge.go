@@ -0,0 +1,352 @@
+package scanner
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	. "util"
+)
+
+const (
+	dot1dBasePortIfIndex        = "1.3.6.1.2.1.17.1.4.1.2"
+	dot1dTpFdbPort              = "1.3.6.1.2.1.17.4.3.1.2"
+	dot1dTpFdbStatus            = "1.3.6.1.2.1.17.4.3.1.3"
+	dot1qTpFdbPort              = "1.3.6.1.2.1.17.7.1.2.2.1.2"
+	dot1qTpFdbStatus            = "1.3.6.1.2.1.17.7.1.2.2.1.3"
+	dot1qVlanFdbId              = "1.3.6.1.2.1.17.7.1.4.2.1.3"
+	dot1qVlanCurrentEgressPorts = "1.3.6.1.2.1.17.7.1.4.2.1.4"
+	dot1qVlanStaticEgressPorts  = "1.3.6.1.2.1.17.7.1.4.3.1.2"
+	dot1qPvid                   = "1.3.6.1.2.1.17.7.1.4.5.1.1"
+	ipNetToPhysicalPhysAddress  = "1.3.6.1.2.1.4.35.1.4"
+	ipNetToMediaPhysAddress     = "1.3.6.1.2.1.4.22.1.2"
+	fdbStatusLearned            = 3
+)
+
+/*
+* 读取dot1dBasePortTable，返回bridge port到ifIndex的映射
+ */
+func (n *NetNodeHandler) BasePortIfIndex() (map[string]int64, error) {
+	result := make(map[string]int64)
+	resp, err := n.walkInteger(dot1dBasePortIfIndex)
+	if err != nil {
+		return nil, err
+	}
+	for port, index := range resp {
+		result[port] = int64(index)
+	}
+	return result, nil
+}
+
+/*
+* 解析OID中以十进制表示的字节，例如MAC地址或IP地址
+ */
+func oidBytes(parts []string) []byte {
+	result := make([]byte, len(parts))
+	for i, part := range parts {
+		b, err := strconv.ParseUint(part, 10, 8)
+		if err != nil {
+			return nil
+		}
+		result[i] = byte(b)
+	}
+	return result
+}
+
+/*
+* 读取动态学习到的MAC地址表，优先使用Q-BRIDGE-MIB dot1qTpFdbTable，
+* 设备不支持时使用BRIDGE-MIB dot1dTpFdbTable，VLAN未知时为0。
+* 共享VLAN学习时多个VLAN对应同一个FDB ID，Vlans为全部候选VLAN
+ */
+func (n *NetNodeHandler) Fdb(baseport map[string]int64) ([]*NetEndpoint, error) {
+	result := []*NetEndpoint{}
+
+	ports, err := n.walkInteger(dot1qTpFdbPort)
+	if err != nil {
+		return nil, err
+	}
+	status, err := n.walkInteger(dot1qTpFdbStatus)
+	if err != nil {
+		return nil, err
+	}
+	qbridge := len(ports) > 0
+
+	fdbvlan := map[int][]int{}
+	if qbridge {
+		// 索引为dot1qVlanTimeMark.dot1qVlanIndex
+		vlans, err := n.walkInteger(dot1qVlanFdbId)
+		if err != nil {
+			return nil, err
+		}
+		for index, fdbid := range vlans {
+			parts := strings.Split(index, ".")
+			if vlan, err := strconv.Atoi(parts[len(parts)-1]); err == nil {
+				fdbvlan[fdbid] = append(fdbvlan[fdbid], vlan)
+			}
+		}
+		for _, vlans := range fdbvlan {
+			sort.Ints(vlans)
+		}
+	} else {
+		if ports, err = n.walkInteger(dot1dTpFdbPort); err != nil {
+			return nil, err
+		}
+		if status, err = n.walkInteger(dot1dTpFdbStatus); err != nil {
+			return nil, err
+		}
+	}
+
+	for index, port := range ports {
+		if s, ok := status[index]; ok && s != fdbStatusLearned {
+			continue
+		}
+		ifindex, ok := baseport[strconv.Itoa(port)]
+		if !ok {
+			continue
+		}
+
+		parts := strings.Split(index, ".")
+		vlan, fdbid := 0, 0
+		var vlans []int
+		if qbridge {
+			if len(parts) != 7 {
+				continue
+			}
+			fdbid, _ = strconv.Atoi(parts[0])
+			vlans = fdbvlan[fdbid]
+			vlan = fdbVlan(fdbid, vlans, n.node.Interfaces[ifindex])
+			parts = parts[1:]
+		}
+		mac := oidBytes(parts)
+		if len(mac) != 6 {
+			continue
+		}
+
+		result = append(result, &NetEndpoint{
+			Mac:     net.HardwareAddr(mac).String(),
+			Vlan:    vlan,
+			Vlans:   vlans,
+			FdbId:   fdbid,
+			IfIndex: ifindex,
+			Port:    "",
+		})
+	}
+	return result, nil
+}
+
+/*
+* 确定FDB ID对应的VLAN，没有dot1qVlanFdbId时FDB ID即为VLAN(独立VLAN学习)。
+* 多个VLAN共享一个FDB ID时使用端口允许通过的VLAN缩小范围，仍无法确定时为0
+ */
+func fdbVlan(fdbid int, vlans []int, intf *NetInterface) int {
+	switch len(vlans) {
+	case 0:
+		return fdbid
+	case 1:
+		return vlans[0]
+	}
+	if intf == nil {
+		return 0
+	}
+	result := 0
+	for _, vlan := range vlans {
+		for _, allowed := range intf.Vlans {
+			if vlan != allowed {
+				continue
+			}
+			if result != 0 {
+				return 0
+			}
+			result = vlan
+		}
+	}
+	return result
+}
+
+/*
+* 读取VLAN的出端口以及端口的native VLAN，写入对应的接口。
+* 优先使用dot1qVlanCurrentTable(dot1qVlanTimeMark.dot1qVlanIndex)，
+* 没有数据时使用dot1qVlanStaticTable(dot1qVlanIndex)
+ */
+func (n *NetNodeHandler) Vlans(baseport map[string]int64, interfaces map[int64]*NetInterface) error {
+	egress, err := n.walkOctetString(dot1qVlanCurrentEgressPorts)
+	if err != nil {
+		return err
+	}
+	if len(egress) == 0 {
+		if egress, err = n.walkOctetString(dot1qVlanStaticEgressPorts); err != nil {
+			return err
+		}
+	}
+
+	vlans := map[int64]map[int]bool{}
+	for index, portlist := range egress {
+		parts := strings.Split(index, ".")
+		vlan, err := strconv.Atoi(parts[len(parts)-1])
+		if err != nil {
+			continue
+		}
+		for _, port := range portListPorts(portlist) {
+			ifindex, ok := baseport[strconv.Itoa(port)]
+			if !ok {
+				continue
+			}
+			if vlans[ifindex] == nil {
+				vlans[ifindex] = map[int]bool{}
+			}
+			vlans[ifindex][vlan] = true
+		}
+	}
+	for ifindex, vlanset := range vlans {
+		if intf, ok := interfaces[ifindex]; ok {
+			intf.Vlans = sortedVlans(vlanset)
+		}
+	}
+
+	pvids, err := n.walkInteger(dot1qPvid)
+	if err != nil {
+		return err
+	}
+	for port, pvid := range pvids {
+		if intf, ok := interfaces[baseport[port]]; ok {
+			intf.Pvid = pvid
+		}
+	}
+	return nil
+}
+
+/*
+* 解析Q-BRIDGE-MIB PortList，第一个字节的最高位为bridge port 1
+ */
+func portListPorts(portlist []byte) []int {
+	result := []int{}
+	for i, b := range portlist {
+		for j := 0; j < 8; j++ {
+			if b&(0x80>>uint(j)) != 0 {
+				result = append(result, i*8+j+1)
+			}
+		}
+	}
+	return result
+}
+
+func sortedVlans(vlanset map[int]bool) []int {
+	result := make([]int, 0, len(vlanset))
+	for vlan := range vlanset {
+		result = append(result, vlan)
+	}
+	sort.Ints(result)
+	return result
+}
+
+/*
+* 生成LINK_TO关系上一端的VLAN属性：端口允许通过的VLAN的并集以及每个端口的native VLAN。
+* 端口属于链路聚合时使用聚合接口上的VLAN
+ */
+func vlanProps(prefix string, node *NetNode, interfaces []*NetInterface) map[string]interface{} {
+	vlanset := map[int]bool{}
+	pvids := make([]int64, len(interfaces))
+	for i, intf := range interfaces {
+		if intf == nil || node == nil {
+			continue
+		}
+		if lag := node.Lag(intf.Index); lag != nil {
+			if agg, ok := node.Interfaces[lag.Index]; ok && (len(agg.Vlans) > 0 || agg.Pvid != 0) {
+				intf = agg
+			}
+		}
+		for _, vlan := range intf.Vlans {
+			vlanset[vlan] = true
+		}
+		pvids[i] = int64(intf.Pvid)
+	}
+
+	vlans := []int64{}
+	for _, vlan := range sortedVlans(vlanset) {
+		vlans = append(vlans, int64(vlan))
+	}
+	return map[string]interface{}{
+		prefix + "vlans": vlans,
+		prefix + "pvids": pvids,
+	}
+}
+
+/*
+* 读取ARP/ND表，返回MAC地址到IP地址的映射。
+* 优先使用ipNetToPhysicalTable(ifIndex.addrType.len.addr)，
+* 设备不支持时使用ipNetToMediaTable(ifIndex.a.b.c.d)
+ */
+func (n *NetNodeHandler) Arp() (map[string][]string, error) {
+	result := make(map[string][]string)
+
+	add := func(mac []byte, ip net.IP) {
+		if len(mac) != 6 || ip == nil {
+			return
+		}
+		key := net.HardwareAddr(mac).String()
+		for _, v := range result[key] {
+			if v == ip.String() {
+				return
+			}
+		}
+		result[key] = append(result[key], ip.String())
+	}
+
+	resp, err := n.walkOctetString(ipNetToPhysicalPhysAddress)
+	if err != nil {
+		return nil, err
+	}
+	for index, mac := range resp {
+		parts := strings.Split(index, ".")
+		if len(parts) < 3 {
+			continue
+		}
+		addr := oidBytes(parts[3:])
+		if len(addr) == net.IPv4len || len(addr) == net.IPv6len {
+			add(mac, net.IP(addr))
+		}
+	}
+	if len(resp) > 0 {
+		return result, nil
+	}
+
+	if resp, err = n.walkOctetString(ipNetToMediaPhysAddress); err != nil {
+		return nil, err
+	}
+	for index, mac := range resp {
+		parts := strings.Split(index, ".")
+		if len(parts) != 5 {
+			continue
+		}
+		add(mac, net.IP(oidBytes(parts[1:])))
+	}
+	return result, nil
+}
+
+/*
+* 只保留边缘端口上学习到的MAC地址，有LLDP/CDP邻居的端口及其所在的链路聚合为上联端口
+ */
+func edgeEndpoints(node *NetNode, neighbors []*NetNeighbor, fdb []*NetEndpoint) []*NetEndpoint {
+	uplinks := map[int64]bool{}
+	for _, neighbor := range neighbors {
+		for _, intf := range neighbor.LocalInterface {
+			if intf == nil {
+				continue
+			}
+			uplinks[intf.Index] = true
+			if lag := node.Lag(intf.Index); lag != nil {
+				uplinks[lag.Index] = true
+			}
+		}
+	}
+
+	result := []*NetEndpoint{}
+	for _, endpoint := range fdb {
+		if uplinks[endpoint.IfIndex] {
+			continue
+		}
+		endpoint.Port = interfaceName(node, endpoint.IfIndex)
+		result = append(result, endpoint)
+	}
+	return result
+}
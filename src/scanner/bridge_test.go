@@ -0,0 +1,57 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+	. "util"
+)
+
+func TestFdbSharedVlan(t *testing.T) {
+	discardLog()
+	dir := t.TempDir()
+	writeCapture(t, dir, "10.0.3.1",
+		"1.3.6.1.2.1.1.2.0|6|1.3.6.1.4.1.2011.2.23",
+		"1.3.6.1.2.1.1.5.0|4|sw1",
+		// VLAN 10和20共享FDB 1
+		"1.3.6.1.2.1.17.7.1.4.2.1.3.0.10|2|1",
+		"1.3.6.1.2.1.17.7.1.4.2.1.3.0.20|2|1",
+		"1.3.6.1.2.1.17.7.1.4.2.1.3.0.30|2|2",
+		"1.3.6.1.2.1.17.7.1.2.2.1.2.1.0.17.34.51.68.1|2|1",
+		"1.3.6.1.2.1.17.7.1.2.2.1.2.1.0.17.34.51.68.2|2|2",
+		"1.3.6.1.2.1.17.7.1.2.2.1.2.2.0.17.34.51.68.3|2|1",
+		"1.3.6.1.2.1.17.7.1.2.2.1.2.5.0.17.34.51.68.4|2|2")
+
+	source, err := NewReplaySource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodehandler, err := source.Dial(&NetNode{Mgt: "10.0.3.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodehandler.node.Interfaces = map[int64]*NetInterface{
+		1: {Index: 1, Vlans: []int{10, 30}},
+		2: {Index: 2, Vlans: []int{10, 20}},
+	}
+	fdb, err := nodehandler.Fdb(map[string]int64{"1": 1, "2": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]NetEndpoint{}
+	for _, endpoint := range fdb {
+		got[endpoint.Mac] = *endpoint
+	}
+	want := map[string]NetEndpoint{
+		// 端口只允许VLAN 10通过
+		"00:11:22:33:44:01": {Mac: "00:11:22:33:44:01", Vlan: 10, Vlans: []int{10, 20}, FdbId: 1, IfIndex: 1},
+		// 端口允许两个VLAN通过，无法确定
+		"00:11:22:33:44:02": {Mac: "00:11:22:33:44:02", Vlan: 0, Vlans: []int{10, 20}, FdbId: 1, IfIndex: 2},
+		"00:11:22:33:44:03": {Mac: "00:11:22:33:44:03", Vlan: 30, Vlans: []int{30}, FdbId: 2, IfIndex: 1},
+		// 没有dot1qVlanFdbId时FDB ID即为VLAN
+		"00:11:22:33:44:04": {Mac: "00:11:22:33:44:04", Vlan: 5, FdbId: 5, IfIndex: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,376 @@
+package scanner
+
+import (
+	"regexp"
+	"strings"
+	. "util"
+)
+
+/*
+* 从命令行输出中解析出的一个邻居
+ */
+type CLINeighbor struct {
+	Protocol      string
+	LocalPort     string
+	RemoteChassis string
+	RemotePort    string
+	RemoteName    string
+	RemoteMgt     string
+}
+
+/*
+* 命令行模板，Parse必须是不依赖设备连接的纯函数，便于使用录制的命令输出验证。
+* LocalCommand读取本端的chassis id和设备名称，由ParseLocal解析
+ */
+type CLITemplate struct {
+	Command      string
+	Parse        func(output string) []*CLINeighbor
+	LocalCommand string
+	ParseLocal   func(output string) (chassis, sysname string)
+}
+
+var (
+	huaweiLLDPTemplate = &CLITemplate{
+		Command:      "display lldp neighbor",
+		Parse:        ParseHuaweiLLDP,
+		LocalCommand: "display lldp local",
+		ParseLocal:   ParseLLDPLocal,
+	}
+	h3cLLDPTemplate = &CLITemplate{
+		Command:      "display lldp neighbor-information verbose",
+		Parse:        ParseHuaweiLLDP,
+		LocalCommand: "display lldp local-information",
+		ParseLocal:   ParseLLDPLocal,
+	}
+	ciscoLLDPTemplate = &CLITemplate{
+		Command:      "show lldp neighbors detail",
+		Parse:        ParseCiscoLLDP,
+		LocalCommand: "show running-config | include ^hostname|^switchname",
+		ParseLocal:   ParseCiscoHostname,
+	}
+	ciscoCDPTemplate = &CLITemplate{
+		Command:      "show cdp neighbors detail",
+		Parse:        ParseCiscoCDP,
+		LocalCommand: "show running-config | include ^hostname|^switchname",
+		ParseLocal:   ParseCiscoHostname,
+	}
+	aristaLLDPTemplate = &CLITemplate{
+		Command:      "show lldp neighbors detail",
+		Parse:        ParseAristaLLDP,
+		LocalCommand: "show lldp local-info",
+		ParseLocal:   ParseLLDPLocal,
+	}
+)
+
+/*
+* 按"键: 值"逐行解析命令输出。header匹配的行开始一个新的本端端口，
+* 其第一个非空分组为本端端口；fields为小写的键到字段名的映射，
+* 字段名为"start"的键或已出现过的字段开始一条新记录。
+* 一个邻居可能通告多个管理地址，mgt字段只保留第一个
+ */
+func parseRecords(output string, header *regexp.Regexp, fields map[string]string, split *regexp.Regexp) []map[string]string {
+	result := []map[string]string{}
+	local := ""
+	current := map[string]string{}
+
+	flush := func() {
+		if len(current) > 0 {
+			if _, ok := current["local"]; !ok && local != "" {
+				current["local"] = local
+			}
+			result = append(result, current)
+		}
+		current = map[string]string{}
+	}
+
+	for _, line := range strings.Split(strings.Replace(output, "\r", "", -1), "\n") {
+		if header != nil {
+			if m := header.FindStringSubmatch(line); m != nil {
+				flush()
+				for _, group := range m[1:] {
+					if group != "" {
+						local = group
+						break
+					}
+				}
+				continue
+			}
+		}
+
+		segments := []string{line}
+		if split != nil {
+			segments = split.Split(line, -1)
+		}
+		for _, segment := range segments {
+			pos := strings.Index(segment, ":")
+			if pos < 0 {
+				continue
+			}
+			key := strings.ToLower(strings.TrimSpace(strings.TrimLeft(segment[:pos], " -")))
+			value := strings.Trim(strings.TrimSpace(segment[pos+1:]), `"`)
+			field, ok := fields[key]
+			if !ok {
+				continue
+			}
+			if field == "start" {
+				flush()
+				continue
+			}
+			if _, ok := current[field]; ok {
+				if field == "mgt" {
+					continue
+				}
+				flush()
+			}
+			if value != "" {
+				current[field] = value
+			}
+		}
+	}
+	flush()
+	return result
+}
+
+var macRegex = regexp.MustCompile(`^[0-9a-fA-F]{12}$`)
+
+/*
+* 命令行中的MAC地址有xxxx-xxxx-xxxx、xxxx.xxxx.xxxx和xx:xx:xx:xx:xx:xx几种格式，
+* 统一为与SNMP相同的小写十六进制
+ */
+func normalizeMac(value string) string {
+	mac := strings.NewReplacer("-", "", ".", "", ":", "").Replace(value)
+	if macRegex.MatchString(mac) {
+		return strings.ToLower(mac)
+	}
+	return value
+}
+
+func cliNeighbors(protocol string, records []map[string]string) []*CLINeighbor {
+	result := make([]*CLINeighbor, 0, len(records))
+	for _, r := range records {
+		if r["local"] == "" || r["chassis"] == "" && r["name"] == "" {
+			continue
+		}
+		result = append(result, &CLINeighbor{
+			Protocol:      protocol,
+			LocalPort:     r["local"],
+			RemoteChassis: normalizeMac(r["chassis"]),
+			RemotePort:    r["port"],
+			RemoteName:    r["name"],
+			RemoteMgt:     r["mgt"],
+		})
+	}
+	return result
+}
+
+// 华为为"GE0/0/1 has 1 neighbor(s):"，H3C为"LLDP neighbor-information of port 1[GE1/0/1]:"
+var huaweiLLDPHeader = regexp.MustCompile(`^\s*(\S+)\s+has\s+\d+\s+neighbor|port\s+\d+\s*\[(\S+)\]`)
+
+/*
+* 华为display lldp neighbor，以及H3C的display lldp neighbor-information
+ */
+func ParseHuaweiLLDP(output string) []*CLINeighbor {
+	records := parseRecords(output, huaweiLLDPHeader, map[string]string{
+		"neighbor index":           "start",
+		"chassis id":               "chassis",
+		"chassisid":                "chassis",
+		"port id":                  "port",
+		"portid":                   "port",
+		"system name":              "name",
+		"sysname":                  "name",
+		"management address":       "mgt",
+		"management address value": "mgt",
+	}, nil)
+	return cliNeighbors("lldp", records)
+}
+
+/*
+* Cisco IOS和NX-OS的show lldp neighbors detail
+ */
+func ParseCiscoLLDP(output string) []*CLINeighbor {
+	records := parseRecords(output, nil, map[string]string{
+		"local intf":         "local",
+		"local port id":      "local",
+		"chassis id":         "chassis",
+		"port id":            "port",
+		"system name":        "name",
+		"ip":                 "mgt",
+		"management address": "mgt",
+	}, nil)
+	return cliNeighbors("lldp", records)
+}
+
+/*
+* Cisco IOS和NX-OS的show cdp neighbors detail，
+* IOS的Interface和Port ID在同一行，以逗号分隔
+ */
+func ParseCiscoCDP(output string) []*CLINeighbor {
+	records := parseRecords(output, nil, map[string]string{
+		"device id":               "name",
+		"interface":               "local",
+		"local interface":         "local",
+		"port id (outgoing port)": "port",
+		"ip address":              "mgt",
+		"ipv4 address":            "mgt",
+	}, regexp.MustCompile(`,\s+`))
+	return cliNeighbors("cdp", records)
+}
+
+/*
+* Arista的show lldp neighbors detail
+ */
+func ParseAristaLLDP(output string) []*CLINeighbor {
+	records := parseRecords(output, regexp.MustCompile(`^\s*Interface\s+(\S+)\s+detected`), map[string]string{
+		"chassis id":         "chassis",
+		"port id":            "port",
+		"system name":        "name",
+		"management address": "mgt",
+	}, nil)
+	return cliNeighbors("lldp", records)
+}
+
+/*
+* 华为display lldp local、H3C display lldp local-information以及Arista show lldp local-info，
+* 取第一个Chassis ID和System name
+ */
+func ParseLLDPLocal(output string) (chassis, sysname string) {
+	records := parseRecords(output, nil, map[string]string{
+		"chassis id":  "chassis",
+		"chassisid":   "chassis",
+		"system name": "name",
+		"sysname":     "name",
+	}, nil)
+	for _, r := range records {
+		if chassis == "" && r["chassis"] != "" {
+			chassis = normalizeMac(r["chassis"])
+		}
+		if sysname == "" && r["name"] != "" {
+			sysname = r["name"]
+		}
+	}
+	return chassis, sysname
+}
+
+var ciscoHostnameRegex = regexp.MustCompile(`(?m)^\s*(?:hostname|switchname)\s+(\S+)`)
+
+/*
+* Cisco IOS和NX-OS的chassis id不能通过命令行直接读取，只使用配置中的设备名称
+ */
+func ParseCiscoHostname(output string) (chassis, sysname string) {
+	if m := ciscoHostnameRegex.FindStringSubmatch(output); m != nil {
+		sysname = m[1]
+	}
+	return "", sysname
+}
+
+/*
+* 按对端设备归并命令行解析出的邻居，生成与SNMP相同的NetNeighbor
+ */
+func cliNetNeighbors(node *NetNode, cli []*CLINeighbor) []*NetNeighbor {
+	neighbors := map[string]*NetNeighbor{}
+	order := []string{}
+	seen := map[string]bool{}
+
+	for _, c := range cli {
+		key := c.RemoteChassis
+		if key == "" {
+			key = deviceName(c.RemoteName)
+		}
+		// LLDP和CDP同时发现的端口只保留一条
+		if seen[c.LocalPort+"/"+key] {
+			continue
+		}
+		seen[c.LocalPort+"/"+key] = true
+
+		neighbor, ok := neighbors[key]
+		if !ok {
+			subtype := ""
+			if c.RemoteChassis != "" && macRegex.MatchString(c.RemoteChassis) {
+				subtype = "mac"
+			}
+			neighbor = NewNetNeighbor(node)
+			neighbor.RemoteName = c.RemoteName
+			neighbor.RemoteChassis = c.RemoteChassis
+			neighbor.ChassisSubtype = subtype
+			neighbors[key] = neighbor
+			order = append(order, key)
+		}
+		if c.RemoteMgt != "" {
+			neighbor.addRemoteID(c.RemoteMgt)
+		}
+		if c.RemoteName != "" {
+			neighbor.addRemoteID(deviceName(c.RemoteName))
+		}
+		neighbor.AddPort(c.Protocol, c.LocalPort, c.RemotePort, "ifName")
+	}
+
+	result := make([]*NetNeighbor, 0, len(order))
+	for _, key := range order {
+		neighbor := neighbors[key]
+		if neighbor.RemoteChassis != "" {
+			neighbor.addRemoteID(neighbor.RemoteChassis)
+		}
+		result = append(result, neighbor)
+	}
+	return result
+}
+
+/*
+* 通过SSH执行厂商模板中的命令采集邻居，用于SNMP不可用的设备
+ */
+type CLICollector struct {
+	Credentials *CredentialStore
+}
+
+func (c *CLICollector) Name() string {
+	return "SSH"
+}
+
+func (c *CLICollector) Match(netnode *NetNode) bool {
+	return c.Credentials.SSH(netnode) != nil
+}
+
+func (c *CLICollector) Collect(netnode *NetNode, register func(id string)) ([]*NetNeighbor, error) {
+	client, err := DialSSH(netnode.Mgt, c.Credentials.SSH(netnode))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	profile := LookupVendorProfile("", netnode)
+	cli := []*CLINeighbor{}
+	local := map[string]bool{}
+	for _, template := range profile.CLITemplates {
+		output, err := client.Run(template.Command)
+		if err != nil {
+			Logger.Printf("[%s] SSH '%s', %v\n", netnode.Mgt, template.Command, err)
+			continue
+		}
+		cli = append(cli, template.Parse(output)...)
+
+		if template.LocalCommand != "" && !local[template.LocalCommand] {
+			local[template.LocalCommand] = true
+			c.registerLocal(client, template, netnode, register)
+		}
+	}
+
+	return cliNetNeighbors(netnode, cli), nil
+}
+
+/*
+* 登记本端的chassis id和设备名称，使其他设备通告的邻居可以解析到本设备
+ */
+func (c *CLICollector) registerLocal(client *SSHClient, template *CLITemplate, netnode *NetNode, register func(id string)) {
+	output, err := client.Run(template.LocalCommand)
+	if err != nil {
+		Logger.Printf("[%s] SSH '%s', %v\n", netnode.Mgt, template.LocalCommand, err)
+		return
+	}
+	chassis, sysname := template.ParseLocal(output)
+	if chassis != "" {
+		register(chassis)
+	}
+	if sysname != "" {
+		register(deviceName(sysname))
+	}
+}
@@ -0,0 +1,217 @@
+package scanner
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	. "util"
+)
+
+func readFixture(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "cli", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestParseCLI(t *testing.T) {
+	tests := []struct {
+		file  string
+		parse func(output string) []*CLINeighbor
+		want  []CLINeighbor
+	}{
+		{"huawei_lldp.txt", ParseHuaweiLLDP, []CLINeighbor{
+			{Protocol: "lldp", LocalPort: "GigabitEthernet0/0/1", RemoteChassis: "00e0fc123456", RemotePort: "GigabitEthernet0/0/2", RemoteName: "SW2", RemoteMgt: "10.1.1.1"},
+			{Protocol: "lldp", LocalPort: "GigabitEthernet0/0/2", RemoteChassis: "00e0fc129999", RemotePort: "GE1/0/1", RemoteName: "SW3"},
+		}},
+		{"h3c_lldp.txt", ParseHuaweiLLDP, []CLINeighbor{
+			{Protocol: "lldp", LocalPort: "GigabitEthernet1/0/1", RemoteChassis: "001122334455", RemotePort: "GigabitEthernet1/0/2", RemoteName: "H3C", RemoteMgt: "192.168.1.1"},
+		}},
+		{"ios_lldp.txt", ParseCiscoLLDP, []CLINeighbor{
+			{Protocol: "lldp", LocalPort: "Gi1/0/1", RemoteChassis: "001122334455", RemotePort: "Gi0/1", RemoteName: "switch2.example.com", RemoteMgt: "10.1.1.2"},
+			{Protocol: "lldp", LocalPort: "Gi1/0/2", RemoteChassis: "001122335555", RemotePort: "Gi0/2", RemoteName: "switch3"},
+		}},
+		{"nxos_lldp.txt", ParseCiscoLLDP, []CLINeighbor{
+			{Protocol: "lldp", LocalPort: "Eth1/49", RemoteChassis: "001122334455", RemotePort: "Eth1/1", RemoteName: "n9k-2", RemoteMgt: "10.1.1.2"},
+			{Protocol: "lldp", LocalPort: "Eth1/50", RemoteChassis: "001122336666", RemotePort: "Eth1/2", RemoteName: "n9k-3"},
+		}},
+		{"cisco_cdp.txt", ParseCiscoCDP, []CLINeighbor{
+			{Protocol: "cdp", LocalPort: "GigabitEthernet1/0/1", RemotePort: "GigabitEthernet0/1", RemoteName: "switch2.example.com", RemoteMgt: "10.1.1.2"},
+			{Protocol: "cdp", LocalPort: "Ethernet1/1", RemotePort: "Ethernet1/2", RemoteName: "n9k(FOX123)", RemoteMgt: "10.2.2.2"},
+		}},
+		{"arista_lldp.txt", ParseAristaLLDP, []CLINeighbor{
+			{Protocol: "lldp", LocalPort: "Ethernet1", RemoteChassis: "001122334455", RemotePort: "Ethernet2", RemoteName: "switch2", RemoteMgt: "10.1.1.2"},
+		}},
+	}
+
+	for _, test := range tests {
+		got := test.parse(readFixture(t, test.file))
+		if len(got) != len(test.want) {
+			t.Errorf("%s: got %d neighbors, want %d", test.file, len(got), len(test.want))
+			continue
+		}
+		for i := range got {
+			if *got[i] != test.want[i] {
+				t.Errorf("%s: neighbor %d = %+v, want %+v", test.file, i, *got[i], test.want[i])
+			}
+		}
+	}
+}
+
+func TestParseCLILocal(t *testing.T) {
+	tests := []struct {
+		file    string
+		parse   func(output string) (string, string)
+		chassis string
+		sysname string
+	}{
+		{"huawei_lldp_local.txt", ParseLLDPLocal, "00e0fc111111", "SW1"},
+		{"h3c_lldp_local.txt", ParseLLDPLocal, "001122330001", "H3C-1"},
+		{"arista_lldp_local.txt", ParseLLDPLocal, "001c73000001", "leaf1"},
+		{"ios_hostname.txt", ParseCiscoHostname, "", "switch1"},
+		{"nxos_hostname.txt", ParseCiscoHostname, "", "n9k-1"},
+	}
+
+	for _, test := range tests {
+		chassis, sysname := test.parse(readFixture(t, test.file))
+		if chassis != test.chassis || sysname != test.sysname {
+			t.Errorf("%s: got %q %q, want %q %q", test.file, chassis, sysname, test.chassis, test.sysname)
+		}
+	}
+}
+
+/*
+* 本地的SSH服务，exec请求按命令返回录制的输出
+ */
+func startSSHServer(t *testing.T, outputs map[string]string) (int, ssh.PublicKey) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() == "admin" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(conn, config, outputs)
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, signer.PublicKey()
+}
+
+func serveSSH(conn net.Conn, config *ssh.ServerConfig, outputs map[string]string) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "session only")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				var exec struct{ Command string }
+				ssh.Unmarshal(req.Payload, &exec)
+				req.Reply(true, nil)
+				channel.Write([]byte(outputs[exec.Command]))
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				return
+			}
+		}()
+	}
+}
+
+func TestCLICollector(t *testing.T) {
+	port, hostkey := startSSHServer(t, map[string]string{
+		aristaLLDPTemplate.Command:      readFixture(t, "arista_lldp.txt"),
+		aristaLLDPTemplate.LocalCommand: readFixture(t, "arista_lldp_local.txt"),
+	})
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))}, hostkey)
+	if err := ioutil.WriteFile(knownHosts, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewCredentialStore(&Config{
+		SNMP: SNMPCredential{Version: "2c", Community: DefaultCommunity},
+		SSH:  SSHCredential{User: "admin", Password: "secret", Port: port, KnownHosts: knownHosts},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collector := &CLICollector{Credentials: store}
+	node := &NetNode{Mgt: "127.0.0.1", Vendor: "Arista"}
+	ids := []string{}
+	neighbors, err := collector.Collect(node, func(id string) { ids = append(ids, id) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != "001c73000001" || ids[1] != deviceName("leaf1") {
+		t.Errorf("registered %v", ids)
+	}
+	if len(neighbors) != 1 {
+		t.Fatalf("got %d neighbors", len(neighbors))
+	}
+	neighbor := neighbors[0]
+	if neighbor.RemoteChassis != "001122334455" || neighbor.LocalPort[0] != "Ethernet1" || neighbor.RemotePort[0] != "Ethernet2" {
+		t.Errorf("neighbor = %+v", *neighbor)
+	}
+}
+
+func TestDialSSHHostKey(t *testing.T) {
+	port, _ := startSSHServer(t, map[string]string{})
+	credential := &SSHCredential{User: "admin", Password: "secret", Port: port}
+
+	if client, err := DialSSH("127.0.0.1", credential); err == nil {
+		client.Close()
+		t.Error("expected an error without known_hosts or skipverify")
+	}
+
+	credential.SkipVerify = true
+	client, err := DialSSH("127.0.0.1", credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+}
@@ -0,0 +1,71 @@
+package scanner
+
+import (
+	. "util"
+)
+
+/*
+* 邻居采集方式。Match判断采集方式是否适用于设备，
+* Collect采集设备的邻居并补充设备信息，通过register登记设备自身的标识(chassis id、设备名称等)，
+* 用于其他设备解析到本设备
+ */
+type Collector interface {
+	Name() string
+	Match(netnode *NetNode) bool
+	Collect(netnode *NetNode, register func(id string)) ([]*NetNeighbor, error)
+}
+
+/*
+* 补充设备信息的采集方式。邻居由其他采集方式得到时，仍然通过Enrich采集设备、接口、路由等信息
+ */
+type Enricher interface {
+	Enrich(netnode *NetNode, neighbors []*NetNeighbor, register func(id string)) error
+}
+
+/*
+* 默认的采集顺序：gNMI、NETCONF、SNMP，SNMP失败时使用SSH命令行。SNMP通过dialer连接设备，
+* 邻居由gNMI、NETCONF或SSH得到时，SNMP可用的设备仍然通过SNMP补充设备信息
+ */
+func DefaultCollectors(credentials *CredentialStore, dialer SNMPDialer, routeroles []string) []Collector {
+	return []Collector{
+		&GNMICollector{Credentials: credentials},
+		&NetconfCollector{Credentials: credentials},
+		&SNMPCollector{Dialer: dialer, RouteRoles: routeroles},
+		&CLICollector{Credentials: credentials},
+	}
+}
+
+/*
+* 由外部构造的邻居，例如mock或离线回放，local为采集到邻居的设备
+ */
+func NewNetNeighbor(local *NetNode) *NetNeighbor {
+	return &NetNeighbor{
+		LocalIP:           local.Mgt,
+		LocalPort:         []string{},
+		RemoteIP:          "",
+		RemotePort:        []string{},
+		RemoteName:        "",
+		RemoteChassis:     "",
+		ChassisSubtype:    "",
+		RemotePortSubtype: []string{},
+		Protocol:          []string{},
+		RemoteIDs:         []string{},
+		LocalInterface:    []*NetInterface{},
+		local:             local,
+	}
+}
+
+/*
+* 增加一个本端端口，interfaces中找不到端口时本端接口为nil
+ */
+func (neighbor *NetNeighbor) AddPort(protocol, localport, remoteport, remotesubtype string) {
+	neighbor.LocalPort = append(neighbor.LocalPort, localport)
+	neighbor.RemotePort = append(neighbor.RemotePort, remoteport)
+	neighbor.RemotePortSubtype = append(neighbor.RemotePortSubtype, remotesubtype)
+	neighbor.Protocol = append(neighbor.Protocol, protocol)
+	neighbor.LocalInterface = append(neighbor.LocalInterface, neighbor.local.Interface(localport))
+}
+
+func (neighbor *NetNeighbor) AddRemoteID(id string) {
+	neighbor.addRemoteID(id)
+}
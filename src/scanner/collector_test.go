@@ -0,0 +1,112 @@
+package scanner
+
+import (
+	"fmt"
+	"testing"
+	. "util"
+)
+
+/*
+* 固定返回结果的采集方式，记录被调用的次数
+ */
+type testCollector struct {
+	name     string
+	err      error
+	collects int
+	enriches int
+}
+
+func (c *testCollector) Name() string {
+	return c.name
+}
+
+func (c *testCollector) Match(netnode *NetNode) bool {
+	return true
+}
+
+func (c *testCollector) Collect(netnode *NetNode, register func(id string)) ([]*NetNeighbor, error) {
+	c.collects++
+	if c.err != nil {
+		return nil, c.err
+	}
+	neighbor := NewNetNeighbor(netnode)
+	neighbor.AddPort("lldp", "Ethernet1", "Ethernet2", "interfaceName")
+	neighbor.AddRemoteID("10.0.0.2")
+	return []*NetNeighbor{neighbor}, nil
+}
+
+type testEnricher struct {
+	testCollector
+}
+
+func (c *testEnricher) Enrich(netnode *NetNode, neighbors []*NetNeighbor, register func(id string)) error {
+	c.enriches++
+	return c.err
+}
+
+var _ Enricher = (*SNMPCollector)(nil)
+
+func newTestScanner(collectors ...Collector) *NetNeighborScanner {
+	return &NetNeighborScanner{
+		NetChassisIdChan:    make(chan [2]string, 10),
+		NetChassisId:        NewSafeMap(10),
+		UnValidNeighborChan: make(chan *NetNeighbor, 10),
+		UnValidNeighbor:     NodeListInit(),
+		ValidNeighborChan:   make(chan *NetNeighbor, 10),
+		Collectors:          collectors,
+	}
+}
+
+func TestScanNeighborEnrich(t *testing.T) {
+	discardLog()
+	node := &NetNode{Mgt: "10.0.0.1"}
+
+	// gNMI采集邻居成功，SNMP补充设备信息
+	gnmi := &testCollector{name: "gNMI"}
+	snmp := &testEnricher{testCollector{name: "SNMP"}}
+	if err := newTestScanner(gnmi, snmp).scanNeighbor(node); err != nil {
+		t.Fatal(err)
+	}
+	if gnmi.collects != 1 || snmp.collects != 0 || snmp.enriches != 1 {
+		t.Errorf("gNMI collects %d, SNMP collects %d enriches %d", gnmi.collects, snmp.collects, snmp.enriches)
+	}
+
+	// SNMP采集失败后由SSH采集邻居，不再重复连接SNMP
+	snmp = &testEnricher{testCollector{name: "SNMP", err: fmt.Errorf("timeout")}}
+	cli := &testCollector{name: "CLI"}
+	if err := newTestScanner(snmp, cli).scanNeighbor(node); err != nil {
+		t.Fatal(err)
+	}
+	if snmp.collects != 1 || snmp.enriches != 0 || cli.collects != 1 {
+		t.Errorf("SNMP collects %d enriches %d, CLI collects %d", snmp.collects, snmp.enriches, cli.collects)
+	}
+
+	// SNMP自身采集邻居时不需要补充
+	snmp = &testEnricher{testCollector{name: "SNMP"}}
+	if err := newTestScanner(snmp).scanNeighbor(node); err != nil {
+		t.Fatal(err)
+	}
+	if snmp.collects != 1 || snmp.enriches != 0 {
+		t.Errorf("SNMP collects %d enriches %d", snmp.collects, snmp.enriches)
+	}
+}
+
+func TestPublishNeighborWithoutRemoteID(t *testing.T) {
+	discardLog()
+	n := newTestScanner()
+	node := &NetNode{Mgt: "10.0.0.1"}
+
+	anonymous := NewNetNeighbor(node)
+	anonymous.AddPort("lldp", "Ethernet1", "", "")
+	unresolved := NewNetNeighbor(node)
+	unresolved.AddPort("lldp", "Ethernet2", "Ethernet1", "interfaceName")
+	unresolved.AddRemoteID("001122334455")
+	n.publishNeighbor([]*NetNeighbor{anonymous, unresolved})
+
+	if len(n.ValidNeighborChan) != 0 || len(n.UnValidNeighborChan) != 1 {
+		t.Fatalf("valid %d, unresolved %d", len(n.ValidNeighborChan), len(n.UnValidNeighborChan))
+	}
+	if neighbor := <-n.UnValidNeighborChan; neighbor.RemoteIP != "001122334455" {
+		t.Errorf("unresolved neighbor = %+v", *neighbor)
+	}
+}
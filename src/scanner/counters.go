@@ -0,0 +1,265 @@
+package scanner
+
+import (
+	"fmt"
+	"github.com/gosnmp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	. "util"
+)
+
+const (
+	ifInDiscards  = "1.3.6.1.2.1.2.2.1.13"
+	ifInErrors    = "1.3.6.1.2.1.2.2.1.14"
+	ifOutDiscards = "1.3.6.1.2.1.2.2.1.19"
+	ifOutErrors   = "1.3.6.1.2.1.2.2.1.20"
+	ifHCInOctets  = "1.3.6.1.2.1.31.1.1.1.6"
+	ifHCOutOctets = "1.3.6.1.2.1.31.1.1.1.10"
+
+	// 每次Get请求的接口数，每个接口6个OID
+	counterBatch = 10
+)
+
+func pduCounter(pdu gosnmp.SnmpPDU) uint64 {
+	switch pdu.Type {
+	case gosnmp.Counter32, gosnmp.Counter64, gosnmp.Gauge32, gosnmp.Integer:
+		return gosnmp.ToBigInt(pdu.Value).Uint64()
+	}
+	return 0
+}
+
+/*
+* 读取指定接口的流量、错包和丢包计数器
+ */
+func (n *NetNodeHandler) Counters(ifindexes []int64) (map[int64]*NetCounters, error) {
+	result := make(map[int64]*NetCounters, len(ifindexes))
+	columns := []string{ifHCInOctets, ifHCOutOctets, ifInErrors, ifOutErrors, ifInDiscards, ifOutDiscards}
+
+	for start := 0; start < len(ifindexes); start += counterBatch {
+		end := start + counterBatch
+		if end > len(ifindexes) {
+			end = len(ifindexes)
+		}
+		oids := make([]string, 0, (end-start)*len(columns))
+		for _, ifindex := range ifindexes[start:end] {
+			for _, column := range columns {
+				oids = append(oids, column+"."+strconv.FormatInt(ifindex, 10))
+			}
+		}
+
+		resp, err := n.snmpd.Get(oids)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		for _, pdu := range resp.Variables {
+			for _, column := range columns {
+				if !strings.HasPrefix(strings.TrimPrefix(pdu.Name, "."), column+".") {
+					continue
+				}
+				ifindex, err := strconv.ParseInt(oidIndex(pdu.Name, column), 10, 64)
+				if err != nil {
+					continue
+				}
+				c, ok := result[ifindex]
+				if !ok {
+					c = &NetCounters{Time: now}
+					result[ifindex] = c
+				}
+				value := pduCounter(pdu)
+				switch column {
+				case ifHCInOctets:
+					c.InOctets = value
+				case ifHCOutOctets:
+					c.OutOctets = value
+				case ifInErrors:
+					c.InErrors = value
+				case ifOutErrors:
+					c.OutErrors = value
+				case ifInDiscards:
+					c.InDiscards = value
+				case ifOutDiscards:
+					c.OutDiscards = value
+				}
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+/*
+* 周期性采集LINK_TO两端端口的计数器，根据相邻两次采样计算速率
+ */
+type CounterPoller struct {
+	Nodes       map[string]*NetNode
+	Links       []*NetNeighbor
+	Credentials *CredentialStore
+
+	last map[string]map[int64]*NetCounters
+}
+
+func NewCounterPoller(netnodes []*NetNode, links []*NetNeighbor, credentials *CredentialStore) *CounterPoller {
+	nodes := make(map[string]*NetNode, len(netnodes))
+	for _, node := range netnodes {
+		nodes[node.Mgt] = node
+	}
+	return &CounterPoller{
+		Nodes:       nodes,
+		Links:       links,
+		Credentials: credentials,
+		last:        map[string]map[int64]*NetCounters{},
+	}
+}
+
+func (p *CounterPoller) remoteInterfaces(link *NetNeighbor) []*NetInterface {
+	remote, ok := p.Nodes[link.RemoteIP]
+	if !ok {
+		return nil
+	}
+	interfaces := make([]*NetInterface, 0, len(link.RemotePort))
+	for _, port := range link.RemotePort {
+		interfaces = append(interfaces, remote.Interface(port))
+	}
+	return interfaces
+}
+
+func (p *CounterPoller) sample(node *NetNode, ifindexes []int64) (map[int64]*NetCounters, error) {
+	credential, ok := p.Credentials.Get(node, node.Credential)
+	if !ok {
+		return nil, fmt.Errorf("Credential '%s' not found", node.Credential)
+	}
+	nodehandler, err := NewNetNodeHandler(node, &credential.SNMPCredential)
+	if err != nil {
+		return nil, err
+	}
+	if err := nodehandler.SNMPConnect(); err != nil {
+		return nil, err
+	}
+	defer nodehandler.SNMPClose()
+	return nodehandler.Counters(ifindexes)
+}
+
+/*
+* 计算一端端口的速率，任一端口没有上次采样或者计数器回绕时返回nil
+ */
+func counterRates(prefix string, interfaces []*NetInterface, last, current map[int64]*NetCounters) map[string]interface{} {
+	var inbps, outbps, errps, discardps float64
+	var speed int64
+	for _, intf := range interfaces {
+		if intf == nil {
+			return nil
+		}
+		prev, ok1 := last[intf.Index]
+		cur, ok2 := current[intf.Index]
+		if !ok1 || !ok2 {
+			return nil
+		}
+		seconds := cur.Time.Sub(prev.Time).Seconds()
+		if seconds <= 0 || cur.InOctets < prev.InOctets || cur.OutOctets < prev.OutOctets ||
+			cur.InErrors < prev.InErrors || cur.OutErrors < prev.OutErrors ||
+			cur.InDiscards < prev.InDiscards || cur.OutDiscards < prev.OutDiscards {
+			return nil
+		}
+		inbps += float64(cur.InOctets-prev.InOctets) * 8 / seconds
+		outbps += float64(cur.OutOctets-prev.OutOctets) * 8 / seconds
+		errps += float64(cur.InErrors-prev.InErrors+cur.OutErrors-prev.OutErrors) / seconds
+		discardps += float64(cur.InDiscards-prev.InDiscards+cur.OutDiscards-prev.OutDiscards) / seconds
+		speed += intf.Speed
+	}
+	if len(interfaces) == 0 {
+		return nil
+	}
+
+	// 利用率取收发方向中较大的一个，接口速率单位为Mbps
+	utilization := 0.0
+	if speed > 0 {
+		utilization = inbps
+		if outbps > utilization {
+			utilization = outbps
+		}
+		utilization = utilization / float64(speed*1000000)
+	}
+	return map[string]interface{}{
+		prefix + "inbps":     inbps,
+		prefix + "outbps":    outbps,
+		prefix + "util":      utilization,
+		prefix + "errps":     errps,
+		prefix + "discardps": discardps,
+	}
+}
+
+/*
+* 采集一次所有链路两端的计数器，返回有速率结果的链路及其属性。
+* 第一次采集只记录计数器
+ */
+func (p *CounterPoller) Poll() map[*NetNeighbor]map[string]interface{} {
+	ifindexes := map[string]map[int64]bool{}
+	add := func(mgt string, interfaces []*NetInterface) {
+		if _, ok := ifindexes[mgt]; !ok {
+			ifindexes[mgt] = map[int64]bool{}
+		}
+		for _, intf := range interfaces {
+			if intf != nil {
+				ifindexes[mgt][intf.Index] = true
+			}
+		}
+	}
+	for _, link := range p.Links {
+		add(link.LocalIP, link.LocalInterface)
+		add(link.RemoteIP, p.remoteInterfaces(link))
+	}
+
+	current := map[string]map[int64]*NetCounters{}
+	lock := sync.Mutex{}
+	threadchan := make(chan struct{}, 100)
+	wait := sync.WaitGroup{}
+	for mgt, indexes := range ifindexes {
+		node, ok := p.Nodes[mgt]
+		if !ok || len(indexes) == 0 || node.Credential == "" {
+			continue
+		}
+		list := make([]int64, 0, len(indexes))
+		for index := range indexes {
+			list = append(list, index)
+		}
+		threadchan <- struct{}{}
+		wait.Add(1)
+		go func(node *NetNode, list []int64) {
+			counters, err := p.sample(node, list)
+			if err != nil {
+				Logger.Printf("[%s] Counters, %v\n", node.Mgt, err)
+			} else {
+				lock.Lock()
+				current[node.Mgt] = counters
+				lock.Unlock()
+			}
+			wait.Done()
+			<-threadchan
+		}(node, list)
+	}
+	wait.Wait()
+
+	result := map[*NetNeighbor]map[string]interface{}{}
+	now := time.Now().Unix()
+	for _, link := range p.Links {
+		props := map[string]interface{}{}
+		for k, v := range counterRates("l", link.LocalInterface, p.last[link.LocalIP], current[link.LocalIP]) {
+			props[k] = v
+		}
+		for k, v := range counterRates("r", p.remoteInterfaces(link), p.last[link.RemoteIP], current[link.RemoteIP]) {
+			props[k] = v
+		}
+		if len(props) > 0 {
+			props["polltime"] = now
+			result[link] = props
+		}
+	}
+
+	for mgt, counters := range current {
+		p.last[mgt] = counters
+	}
+	return result
+}
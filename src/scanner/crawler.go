@@ -0,0 +1,205 @@
+package scanner
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	. "util"
+)
+
+/*
+* 从种子管理地址开始，按LLDP/CDP通告的管理地址逐层发现设备，
+* 不依赖CMDB生成NetNode
+ */
+type Crawler struct {
+	Seeds    []string
+	Allow    []*net.IPNet // 为空时不限制
+	Deny     []*net.IPNet
+	MaxDepth int // 种子为第0层
+	Dialer   SNMPDialer
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	result := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ipnet)
+	}
+	return result, nil
+}
+
+func NewCrawler(c *CrawlConfig, dialer SNMPDialer) (*Crawler, error) {
+	allow, err := parseCIDRs(c.Allow)
+	if err != nil {
+		return nil, fmt.Errorf("Crawl allow list has invalid cidr. %v", err)
+	}
+	deny, err := parseCIDRs(c.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("Crawl deny list has invalid cidr. %v", err)
+	}
+	return &Crawler{
+		Seeds:    c.Seeds,
+		Allow:    allow,
+		Deny:     deny,
+		MaxDepth: c.MaxDepth,
+		Dialer:   dialer,
+	}, nil
+}
+
+func (c *Crawler) permit(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil || ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, ipnet := range c.Deny {
+		if ipnet.Contains(ip) {
+			return false
+		}
+	}
+	if len(c.Allow) == 0 {
+		return true
+	}
+	for _, ipnet := range c.Allow {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+type crawlResult struct {
+	node      *NetNode
+	chassis   []string
+	neighbors []string
+}
+
+/*
+* 读取设备的系统信息、本端chassis id以及邻居通告的管理地址
+ */
+func (c *Crawler) visit(node *NetNode) (*crawlResult, error) {
+	nodehandler, err := c.Dialer.Dial(node)
+	if err != nil {
+		return nil, err
+	}
+	defer nodehandler.SNMPClose()
+
+	if err := nodehandler.System(); err != nil {
+		Logger.Printf("[%s] System, %v\n", node.Mgt, err)
+	}
+	node.Name = node.SysName
+	node.Vendor = node.SysVendor
+
+	chassis, err := nodehandler.SelfChassisID()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &crawlResult{node: node, chassis: chassis}
+	addresses, err := nodehandler.RemManAddr()
+	if err != nil {
+		Logger.Printf("[%s] LLDP management address, %v\n", node.Mgt, err)
+	}
+	for _, addrs := range addresses {
+		result.neighbors = append(result.neighbors, addrs...)
+	}
+	cdp, err := nodehandler.CDPCache()
+	if err != nil {
+		Logger.Printf("[%s] CDP, %v\n", node.Mgt, err)
+	}
+	for _, entry := range cdp {
+		if entry.Address != "" {
+			result.neighbors = append(result.neighbors, entry.Address)
+		}
+	}
+	return result, nil
+}
+
+/*
+* 逐层发现设备，同一层的设备并发扫描。
+* 同一台设备可能通过多个管理地址被发现，以chassis id去重，没有chassis id时以管理地址区分
+ */
+func (c *Crawler) Crawl() []*NetNode {
+	result := []*NetNode{}
+	visited := map[string]bool{}
+	devices := map[string]string{}
+
+	current := []string{}
+	for _, seed := range c.Seeds {
+		if visited[seed] {
+			continue
+		}
+		visited[seed] = true
+		if !c.permit(seed) {
+			Logger.Printf("[%s] Crawl, seed is not permitted\n", seed)
+			continue
+		}
+		current = append(current, seed)
+	}
+
+	for depth := 0; len(current) > 0 && depth <= c.MaxDepth; depth++ {
+		results := make([]*crawlResult, len(current))
+		threadchan := make(chan struct{}, 100)
+		wait := sync.WaitGroup{}
+		for i, mgt := range current {
+			threadchan <- struct{}{}
+			wait.Add(1)
+			go func(i int, mgt string) {
+				node := &NetNode{Mgt: mgt, Lables: []string{"SWITCH"}}
+				r, err := c.visit(node)
+				if err != nil {
+					Logger.Printf("[%s] Crawl, %v\n", mgt, err)
+				}
+				results[i] = r
+				wait.Done()
+				<-threadchan
+			}(i, mgt)
+		}
+		wait.Wait()
+
+		next := []string{}
+		for _, r := range results {
+			if r == nil {
+				continue
+			}
+			// 不同设备的sysName可能相同(例如出厂默认名称)，不用于去重
+			ids := []string{}
+			for _, id := range r.chassis {
+				if id != "" {
+					ids = append(ids, id)
+				}
+			}
+			if len(ids) == 0 {
+				ids = append(ids, r.node.Mgt)
+			}
+			duplicate := ""
+			for _, id := range ids {
+				if mgt, ok := devices[id]; ok {
+					duplicate = mgt
+					break
+				}
+			}
+			if duplicate != "" {
+				Logger.Printf("[%s] Crawl, same device as %s\n", r.node.Mgt, duplicate)
+				continue
+			}
+			for _, id := range ids {
+				devices[id] = r.node.Mgt
+			}
+
+			r.node.Id = GenNodeID(r.node.Mgt)
+			result = append(result, r.node)
+
+			for _, addr := range r.neighbors {
+				if !visited[addr] && c.permit(addr) {
+					visited[addr] = true
+					next = append(next, addr)
+				}
+			}
+		}
+		current = next
+	}
+	return result
+}
@@ -0,0 +1,62 @@
+package scanner
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	. "util"
+)
+
+func writeCapture(t *testing.T, dir, mgt string, lines ...string) {
+	data := []byte(strings.Join(lines, "\n") + "\n")
+	if err := ioutil.WriteFile(filepath.Join(dir, mgt+".snmprec"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCrawlDuplicate(t *testing.T) {
+	discardLog()
+	dir := t.TempDir()
+	writeCapture(t, dir, "10.0.1.1",
+		"1.3.6.1.2.1.1.2.0|6|1.3.6.1.4.1.9.1.516",
+		"1.3.6.1.2.1.1.5.0|4|core",
+		"1.0.8802.1.1.2.1.3.1.0|2|4",
+		"1.0.8802.1.1.2.1.3.2.0|4x|0011223344aa",
+		"1.0.8802.1.1.2.1.4.2.1.3.0.1.1.1.4.10.0.1.2|2|2",
+		"1.0.8802.1.1.2.1.4.2.1.3.0.2.1.1.4.10.0.1.3|2|2",
+		"1.0.8802.1.1.2.1.4.2.1.3.0.3.1.1.4.10.0.1.4|2|2")
+	// 两台出厂默认名称、没有chassis id的设备
+	for _, mgt := range []string{"10.0.1.2", "10.0.1.3"} {
+		writeCapture(t, dir, mgt,
+			"1.3.6.1.2.1.1.2.0|6|1.3.6.1.4.1.9.1.516",
+			"1.3.6.1.2.1.1.5.0|4|Switch",
+			"1.0.8802.1.1.2.1.3.1.0|2|4",
+			"1.0.8802.1.1.2.1.3.2.0|4x|000000000000")
+	}
+	// core的另一个管理地址
+	writeCapture(t, dir, "10.0.1.4",
+		"1.3.6.1.2.1.1.2.0|6|1.3.6.1.4.1.9.1.516",
+		"1.3.6.1.2.1.1.5.0|4|core",
+		"1.0.8802.1.1.2.1.3.1.0|2|4",
+		"1.0.8802.1.1.2.1.3.2.0|4x|0011223344aa")
+
+	source, err := NewReplaySource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crawler, err := NewCrawler(&CrawlConfig{Seeds: []string{"10.0.1.1"}, MaxDepth: 1}, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mgts := []string{}
+	for _, node := range crawler.Crawl() {
+		mgts = append(mgts, node.Mgt)
+	}
+	sort.Strings(mgts)
+	if strings.Join(mgts, ",") != "10.0.1.1,10.0.1.2,10.0.1.3" {
+		t.Errorf("crawled %v", mgts)
+	}
+}
@@ -0,0 +1,146 @@
+package scanner
+
+import (
+	"regexp"
+	"strconv"
+	. "util"
+)
+
+const (
+	entPhysicalDescr       = "1.3.6.1.2.1.47.1.1.1.1.2"
+	entPhysicalContainedIn = "1.3.6.1.2.1.47.1.1.1.1.4"
+	entPhysicalClass       = "1.3.6.1.2.1.47.1.1.1.1.5"
+	entPhysicalName        = "1.3.6.1.2.1.47.1.1.1.1.7"
+	entPhysicalHardwareRev = "1.3.6.1.2.1.47.1.1.1.1.8"
+	entPhysicalFirmwareRev = "1.3.6.1.2.1.47.1.1.1.1.9"
+	entPhysicalSoftwareRev = "1.3.6.1.2.1.47.1.1.1.1.10"
+	entPhysicalSerialNum   = "1.3.6.1.2.1.47.1.1.1.1.11"
+	entPhysicalModelName   = "1.3.6.1.2.1.47.1.1.1.1.13"
+
+	entClassChassis     = 3
+	entClassContainer   = 5
+	entClassPowerSupply = 6
+	entClassFan         = 7
+	entClassModule      = 9
+	entClassPort        = 10
+)
+
+var entClassName = map[int]string{
+	entClassChassis:     "chassis",
+	entClassPowerSupply: "powerSupply",
+	entClassFan:         "fan",
+	entClassModule:      "module",
+}
+
+var transceiverRegex = regexp.MustCompile(`(?i)sfp|xfp|cfp|qsfp|transceiver|optic`)
+
+/*
+* entPhysicalTable中的一行
+ */
+type entity struct {
+	Index       int64
+	Class       int
+	ContainedIn int64
+	Name        string
+	Descr       string
+	HardwareRev string
+	FirmwareRev string
+	SoftwareRev string
+	Serial      string
+	Model       string
+}
+
+func (n *NetNodeHandler) Entities() (map[int64]*entity, error) {
+	result := make(map[int64]*entity)
+
+	classes, err := n.walkInteger(entPhysicalClass)
+	if err != nil {
+		return nil, err
+	}
+	if len(classes) == 0 {
+		return result, nil
+	}
+	parents, err := n.walkInteger(entPhysicalContainedIn)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]map[string][]byte{
+		entPhysicalDescr:       nil,
+		entPhysicalName:        nil,
+		entPhysicalHardwareRev: nil,
+		entPhysicalFirmwareRev: nil,
+		entPhysicalSoftwareRev: nil,
+		entPhysicalSerialNum:   nil,
+		entPhysicalModelName:   nil,
+	}
+	for oid := range columns {
+		if columns[oid], err = n.walkOctetString(oid); err != nil {
+			return nil, err
+		}
+	}
+
+	for index, class := range classes {
+		i, err := strconv.ParseInt(index, 10, 64)
+		if err != nil {
+			continue
+		}
+		result[i] = &entity{
+			Index:       i,
+			Class:       class,
+			ContainedIn: int64(parents[index]),
+			Name:        string(columns[entPhysicalName][index]),
+			Descr:       string(columns[entPhysicalDescr][index]),
+			HardwareRev: string(columns[entPhysicalHardwareRev][index]),
+			FirmwareRev: string(columns[entPhysicalFirmwareRev][index]),
+			SoftwareRev: string(columns[entPhysicalSoftwareRev][index]),
+			Serial:      string(columns[entPhysicalSerialNum][index]),
+			Model:       string(columns[entPhysicalModelName][index]),
+		}
+	}
+	return result, nil
+}
+
+/*
+* 从entPhysicalTable中选出机框、板卡、电源、风扇和光模块。
+* 光模块为包含在端口中的module，Slot为最近的上级槽位或端口的名称
+ */
+func modules(entities map[int64]*entity) []*NetModule {
+	result := []*NetModule{}
+	for _, e := range entities {
+		class, ok := entClassName[e.Class]
+		if !ok {
+			continue
+		}
+
+		slot := ""
+		depth := 0
+		for parent := entities[e.ContainedIn]; parent != nil && depth < 16; parent = entities[parent.ContainedIn] {
+			if parent.Class == entClassPort && e.Class == entClassModule {
+				class = "transceiver"
+			}
+			if parent.Class == entClassContainer || parent.Class == entClassPort {
+				slot = parent.Name
+				break
+			}
+			depth += 1
+		}
+		if class == "module" && transceiverRegex.MatchString(e.Descr) {
+			class = "transceiver"
+		}
+
+		result = append(result, &NetModule{
+			Index:       e.Index,
+			Class:       class,
+			Name:        e.Name,
+			Descr:       e.Descr,
+			Model:       e.Model,
+			Serial:      e.Serial,
+			HardwareRev: e.HardwareRev,
+			FirmwareRev: e.FirmwareRev,
+			SoftwareRev: e.SoftwareRev,
+			Slot:        slot,
+		})
+	}
+	return result
+}
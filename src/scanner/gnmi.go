@@ -0,0 +1,501 @@
+package scanner
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	. "util"
+)
+
+const (
+	gnmiInterfacesPath = "/interfaces"
+	gnmiLLDPPath       = "/lldp"
+	gnmiSystemPath     = "/system/state"
+)
+
+type GNMIClient struct {
+	conn       *grpc.ClientConn
+	client     gnmi.GNMIClient
+	credential *GNMICredential
+}
+
+func DialGNMI(host string, credential *GNMICredential) (*GNMIClient, error) {
+	port := credential.Port
+	if port == 0 {
+		port = 6030
+	}
+
+	transport := insecure.NewCredentials()
+	if credential.TLS {
+		config := &tls.Config{InsecureSkipVerify: credential.SkipVerify}
+		if credential.CA != "" {
+			pem, err := ioutil.ReadFile(credential.CA)
+			if err != nil {
+				return nil, err
+			}
+			config.RootCAs = x509.NewCertPool()
+			if !config.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("No certificate found in %s", credential.CA)
+			}
+		}
+		transport = credentials.NewTLS(config)
+	}
+
+	// 连接在第一次请求时建立，连接失败由请求返回
+	conn, err := grpc.NewClient(net.JoinHostPort(host, strconv.Itoa(port)), grpc.WithTransportCredentials(transport))
+	if err != nil {
+		return nil, err
+	}
+	return &GNMIClient{conn: conn, client: gnmi.NewGNMIClient(conn), credential: credential}, nil
+}
+
+func (c *GNMIClient) Close() error {
+	return c.conn.Close()
+}
+
+func gnmiPath(path string) *gnmi.Path {
+	result := &gnmi.Path{}
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		if name != "" {
+			result.Elem = append(result.Elem, &gnmi.PathElem{Name: name})
+		}
+	}
+	return result
+}
+
+/*
+* 使用JSON_IETF编码获取path对应容器的内容。
+* 设备可能在path下任意层级分别返回多个update，按每个update的完整路径合并成一棵JSON树，
+* 列表元素按路径中的key合并
+ */
+func (c *GNMIClient) Get(path string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if c.credential.User != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "username", c.credential.User, "password", c.credential.Password)
+	}
+
+	resp, err := c.client.Get(ctx, &gnmi.GetRequest{
+		Path:     []*gnmi.Path{gnmiPath(path)},
+		Encoding: gnmi.Encoding_JSON_IETF,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	target := gnmiPath(path).Elem
+	root := map[string]interface{}{}
+	for _, notification := range resp.Notification {
+		for _, update := range notification.Update {
+			elems := []*gnmi.PathElem{}
+			if notification.Prefix != nil {
+				elems = append(elems, notification.Prefix.Elem...)
+			}
+			if update.Path != nil {
+				elems = append(elems, update.Path.Elem...)
+			}
+			if len(elems) < len(target) {
+				return nil, fmt.Errorf("update path is outside of %s", path)
+			}
+			for i, elem := range target {
+				if stripName(elems[i].Name) != elem.Name {
+					return nil, fmt.Errorf("update path is outside of %s", path)
+				}
+			}
+
+			value, err := gnmiValue(update.Val)
+			if err != nil {
+				return nil, err
+			}
+			value = stripModule(value)
+
+			relative := elems[len(target):]
+			if len(relative) == 0 {
+				// 内容可能包含path本身这一层，例如{"lldp": {...}}
+				if m, ok := value.(map[string]interface{}); ok && len(m) == 1 {
+					if inner, ok := m[target[len(target)-1].Name]; ok {
+						value = inner
+					}
+				}
+				m, ok := value.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("%s is not a container", path)
+				}
+				mergeJSON(root, m)
+				continue
+			}
+			insertJSON(root, relative, value)
+		}
+	}
+	return json.Marshal(root)
+}
+
+func stripName(name string) string {
+	if pos := strings.LastIndex(name, ":"); pos >= 0 {
+		return name[pos+1:]
+	}
+	return name
+}
+
+/*
+* JSON编码的值解码后返回，其他编码返回对应的标量
+ */
+func gnmiValue(val *gnmi.TypedValue) (interface{}, error) {
+	data := val.GetJsonIetfVal()
+	if data == nil {
+		data = val.GetJsonVal()
+	}
+	if data != nil {
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+
+	switch v := val.GetValue().(type) {
+	case *gnmi.TypedValue_StringVal:
+		return v.StringVal, nil
+	case *gnmi.TypedValue_IntVal:
+		return v.IntVal, nil
+	case *gnmi.TypedValue_UintVal:
+		return v.UintVal, nil
+	case *gnmi.TypedValue_BoolVal:
+		return v.BoolVal, nil
+	case *gnmi.TypedValue_DoubleVal:
+		return v.DoubleVal, nil
+	case nil:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("Unsupport gNMI value %T", val.GetValue())
+}
+
+/*
+* 按相对路径把value放入node，带key的路径元素为列表元素，缺少的key补到元素中
+ */
+func insertJSON(node map[string]interface{}, elems []*gnmi.PathElem, value interface{}) {
+	for i, elem := range elems {
+		name := stripName(elem.Name)
+		last := i == len(elems)-1
+
+		if len(elem.Key) == 0 {
+			if last {
+				current, ok1 := node[name].(map[string]interface{})
+				update, ok2 := value.(map[string]interface{})
+				if ok1 && ok2 {
+					mergeJSON(current, update)
+				} else {
+					node[name] = value
+				}
+				return
+			}
+			child, ok := node[name].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				node[name] = child
+			}
+			node = child
+			continue
+		}
+
+		list, _ := node[name].([]interface{})
+		var element map[string]interface{}
+		for _, item := range list {
+			if m, ok := item.(map[string]interface{}); ok && matchKeys(m, elem.Key) {
+				element = m
+				break
+			}
+		}
+		if element == nil {
+			element = map[string]interface{}{}
+			for k, v := range elem.Key {
+				element[k] = v
+			}
+			node[name] = append(list, element)
+		}
+		if last {
+			if update, ok := value.(map[string]interface{}); ok {
+				mergeJSON(element, update)
+			}
+			return
+		}
+		node = element
+	}
+}
+
+func matchKeys(element map[string]interface{}, keys map[string]string) bool {
+	for k, v := range keys {
+		if fmt.Sprint(element[k]) != v {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+* 把src合并到dst，两边都是对象时递归合并，列表按元素追加
+ */
+func mergeJSON(dst, src map[string]interface{}) {
+	for k, v := range src {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			if current, ok := dst[k].(map[string]interface{}); ok {
+				mergeJSON(current, value)
+				continue
+			}
+		case []interface{}:
+			if current, ok := dst[k].([]interface{}); ok {
+				dst[k] = append(current, value...)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+/*
+* 去掉JSON_IETF中"模块名:"形式的键前缀
+ */
+func stripModule(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if pos := strings.LastIndex(key, ":"); pos >= 0 {
+				key = key[pos+1:]
+			}
+			result[key] = stripModule(item)
+		}
+		return result
+	case []interface{}:
+		for i, item := range v {
+			v[i] = stripModule(item)
+		}
+		return v
+	}
+	return value
+}
+
+/*
+* 解码去掉模块前缀的JSON，设备返回的内容可能包含path本身这一层，例如{"interfaces": {...}}
+ */
+func decodeOpenConfig(data []byte, container string, result interface{}) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	value = stripModule(value)
+	if m, ok := value.(map[string]interface{}); ok {
+		if inner, ok := m[container]; ok && len(m) == 1 {
+			value = inner
+		}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+var ocSpeedRegex = regexp.MustCompile(`SPEED_(\d+)(MB|GB)`)
+
+var ocInterfaceTypes = map[string]int{
+	"ethernetCsmacd":   6,
+	"softwareLoopback": 24,
+	"l3ipvlan":         136,
+	"ieee8023adLag":    161,
+}
+
+type ocInterfaces struct {
+	Interface []struct {
+		Name  string `json:"name"`
+		State struct {
+			Ifindex     int64  `json:"ifindex"`
+			Type        string `json:"type"`
+			Description string `json:"description"`
+			OperStatus  string `json:"oper-status"`
+			AdminStatus string `json:"admin-status"`
+		} `json:"state"`
+		Ethernet struct {
+			State struct {
+				PortSpeed string `json:"port-speed"`
+			} `json:"state"`
+		} `json:"ethernet"`
+	} `json:"interface"`
+}
+
+/*
+* 解析openconfig-interfaces的/interfaces，没有ifindex的接口按名称顺序编号
+ */
+func ParseGNMIInterfaces(data []byte) (map[int64]*NetInterface, error) {
+	var oc ocInterfaces
+	if err := decodeOpenConfig(data, "interfaces", &oc); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(oc.Interface, func(i, j int) bool { return oc.Interface[i].Name < oc.Interface[j].Name })
+	result := make(map[int64]*NetInterface, len(oc.Interface))
+	next := int64(1000000)
+	for _, i := range oc.Interface {
+		index := i.State.Ifindex
+		if index == 0 {
+			next++
+			index = next
+		}
+
+		speed := int64(0)
+		if m := ocSpeedRegex.FindStringSubmatch(i.Ethernet.State.PortSpeed); m != nil {
+			speed, _ = strconv.ParseInt(m[1], 10, 64)
+			if m[2] == "GB" {
+				speed *= 1000
+			}
+		}
+		iftype := i.State.Type
+		if pos := strings.LastIndex(iftype, ":"); pos >= 0 {
+			iftype = iftype[pos+1:]
+		}
+
+		result[index] = &NetInterface{
+			Index:       index,
+			Type:        ocInterfaceTypes[iftype],
+			Name:        i.Name,
+			Descr:       i.Name,
+			Alias:       i.State.Description,
+			Speed:       speed,
+			OperStatus:  strings.ToLower(i.State.OperStatus),
+			AdminStatus: strings.ToLower(i.State.AdminStatus),
+		}
+	}
+	return result, nil
+}
+
+type ocLLDP struct {
+	State struct {
+		ChassisId  string `json:"chassis-id"`
+		SystemName string `json:"system-name"`
+	} `json:"state"`
+	Interfaces struct {
+		Interface []struct {
+			Name      string `json:"name"`
+			Neighbors struct {
+				Neighbor []struct {
+					State struct {
+						ChassisId         string `json:"chassis-id"`
+						PortId            string `json:"port-id"`
+						SystemName        string `json:"system-name"`
+						ManagementAddress string `json:"management-address"`
+					} `json:"state"`
+				} `json:"neighbor"`
+			} `json:"neighbors"`
+		} `json:"interface"`
+	} `json:"interfaces"`
+}
+
+/*
+* 解析openconfig-lldp的/lldp，返回本端chassis id和邻居
+ */
+func ParseGNMILLDP(data []byte) (string, []*CLINeighbor, error) {
+	var oc ocLLDP
+	if err := decodeOpenConfig(data, "lldp", &oc); err != nil {
+		return "", nil, err
+	}
+
+	result := []*CLINeighbor{}
+	for _, i := range oc.Interfaces.Interface {
+		for _, neighbor := range i.Neighbors.Neighbor {
+			result = append(result, &CLINeighbor{
+				Protocol:      "lldp",
+				LocalPort:     i.Name,
+				RemoteChassis: normalizeMac(neighbor.State.ChassisId),
+				RemotePort:    neighbor.State.PortId,
+				RemoteName:    neighbor.State.SystemName,
+				RemoteMgt:     neighbor.State.ManagementAddress,
+			})
+		}
+	}
+	return normalizeMac(oc.State.ChassisId), result, nil
+}
+
+/*
+* 解析openconfig-system的/system/state，返回设备名称
+ */
+func ParseGNMIHostname(data []byte) string {
+	var state struct {
+		Hostname string `json:"hostname"`
+	}
+	if err := decodeOpenConfig(data, "state", &state); err != nil {
+		return ""
+	}
+	return state.Hostname
+}
+
+/*
+* 通过gNMI读取接口和LLDP邻居，用于gNMI网段内的设备
+ */
+type GNMICollector struct {
+	Credentials *CredentialStore
+}
+
+func (c *GNMICollector) Name() string {
+	return "gNMI"
+}
+
+func (c *GNMICollector) Match(netnode *NetNode) bool {
+	return c.Credentials.GNMI(netnode) != nil
+}
+
+func (c *GNMICollector) Collect(netnode *NetNode, register func(id string)) ([]*NetNeighbor, error) {
+	client, err := DialGNMI(netnode.Mgt, c.Credentials.GNMI(netnode))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	data, err := client.Get(gnmiInterfacesPath)
+	if err != nil {
+		return nil, fmt.Errorf("Get %s, %v", gnmiInterfacesPath, err)
+	}
+	interfaces, err := ParseGNMIInterfaces(data)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = client.Get(gnmiLLDPPath)
+	if err != nil {
+		return nil, fmt.Errorf("Get %s, %v", gnmiLLDPPath, err)
+	}
+	chassis, neighbors, err := ParseGNMILLDP(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(neighbors) == 0 {
+		return nil, fmt.Errorf("No LLDP neighbor returned")
+	}
+
+	netnode.Interfaces = interfaces
+	netnode.Ports = portIndex(interfaces, nil)
+	if chassis != "" {
+		register(chassis)
+	}
+	if data, err := client.Get(gnmiSystemPath); err != nil {
+		Logger.Printf("[%s] gNMI Get %s, %v\n", netnode.Mgt, gnmiSystemPath, err)
+	} else if hostname := ParseGNMIHostname(data); hostname != "" {
+		register(deviceName(hostname))
+	}
+
+	return cliNetNeighbors(netnode, neighbors), nil
+}
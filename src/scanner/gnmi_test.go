@@ -0,0 +1,281 @@
+package scanner
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/openconfig/gnmi/proto/gnmi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	. "util"
+)
+
+/*
+* 本地的gNMI服务，按请求的路径返回固定的notification
+ */
+type stubGNMIServer struct {
+	gnmi.UnimplementedGNMIServer
+	responses map[string][]*gnmi.Notification
+}
+
+func (s *stubGNMIServer) Get(ctx context.Context, req *gnmi.GetRequest) (*gnmi.GetResponse, error) {
+	names := []string{}
+	for _, elem := range req.Path[0].Elem {
+		names = append(names, elem.Name)
+	}
+	return &gnmi.GetResponse{Notification: s.responses["/"+strings.Join(names, "/")]}, nil
+}
+
+func startGNMIServer(t *testing.T, responses map[string][]*gnmi.Notification, opts ...grpc.ServerOption) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(opts...)
+	gnmi.RegisterGNMIServer(server, &stubGNMIServer{responses: responses})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func testPath(elems ...*gnmi.PathElem) *gnmi.Path {
+	return &gnmi.Path{Elem: elems}
+}
+
+func elem(name string, keys ...string) *gnmi.PathElem {
+	e := &gnmi.PathElem{Name: name}
+	if len(keys) > 0 {
+		e.Key = map[string]string{}
+		for i := 0; i+1 < len(keys); i += 2 {
+			e.Key[keys[i]] = keys[i+1]
+		}
+	}
+	return e
+}
+
+func jsonUpdate(path *gnmi.Path, value string) *gnmi.Update {
+	return &gnmi.Update{Path: path, Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_JsonIetfVal{JsonIetfVal: []byte(value)}}}
+}
+
+var testGNMIInterfaces = []*gnmi.Notification{{
+	Update: []*gnmi.Update{
+		jsonUpdate(testPath(elem("interfaces"), elem("interface", "name", "Ethernet1")),
+			`{"openconfig-interfaces:state": {"ifindex": 1, "type": "iana-if-type:ethernetCsmacd", "oper-status": "UP", "admin-status": "UP"},
+			  "openconfig-if-ethernet:ethernet": {"state": {"port-speed": "openconfig-if-ethernet:SPEED_100GB"}}}`),
+		jsonUpdate(testPath(elem("interfaces"), elem("interface", "name", "Ethernet2")),
+			`{"openconfig-interfaces:state": {"ifindex": 2, "type": "iana-if-type:ethernetCsmacd", "oper-status": "DOWN", "admin-status": "UP"}}`),
+	},
+}}
+
+const testGNMINeighbor = `{"state": {"chassis-id": "aa:bb:cc:dd:ee:01", "port-id": "Ethernet49/1", "system-name": "spine1", "management-address": "10.0.0.2"}}`
+
+/*
+* 同一份LLDP数据的不同返回方式
+ */
+var testGNMILLDPShapes = map[string][]*gnmi.Notification{
+	"container": {{
+		Update: []*gnmi.Update{
+			jsonUpdate(testPath(elem("lldp")), `{"openconfig-lldp:lldp": {
+				"state": {"chassis-id": "00:11:22:33:44:55", "system-name": "leaf1"},
+				"interfaces": {"interface": [{"name": "Ethernet1", "neighbors": {"neighbor": [`+testGNMINeighbor+`]}}]}}}`),
+		},
+	}},
+	"subcontainers": {{
+		Update: []*gnmi.Update{
+			jsonUpdate(testPath(elem("lldp"), elem("state")), `{"chassis-id": "00:11:22:33:44:55", "system-name": "leaf1"}`),
+			jsonUpdate(testPath(elem("lldp"), elem("interfaces")),
+				`{"interface": [{"name": "Ethernet1", "neighbors": {"neighbor": [`+testGNMINeighbor+`]}}]}`),
+		},
+	}},
+	"elements": {{
+		Prefix: testPath(elem("openconfig-lldp:lldp")),
+		Update: []*gnmi.Update{
+			{Path: testPath(elem("state"), elem("chassis-id")), Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_StringVal{StringVal: "00:11:22:33:44:55"}}},
+			jsonUpdate(testPath(elem("interfaces"), elem("interface", "name", "Ethernet1")), `{"config": {"enabled": true}}`),
+		},
+	}, {
+		Prefix: testPath(elem("lldp"), elem("interfaces"), elem("interface", "name", "Ethernet1")),
+		Update: []*gnmi.Update{
+			jsonUpdate(testPath(elem("neighbors"), elem("neighbor", "id", "1")), testGNMINeighbor),
+		},
+	}},
+}
+
+func TestGNMIGet(t *testing.T) {
+	for shape, lldp := range testGNMILLDPShapes {
+		port := startGNMIServer(t, map[string][]*gnmi.Notification{"/interfaces": testGNMIInterfaces, "/lldp": lldp})
+		client, err := DialGNMI("127.0.0.1", &GNMICredential{Port: port})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := client.Get(gnmiLLDPPath)
+		if err != nil {
+			t.Fatalf("%s: %v", shape, err)
+		}
+		chassis, neighbors, err := ParseGNMILLDP(data)
+		if err != nil {
+			t.Fatalf("%s: %v", shape, err)
+		}
+		if chassis != "001122334455" {
+			t.Errorf("%s: chassis = %q", shape, chassis)
+		}
+		if len(neighbors) != 1 {
+			t.Fatalf("%s: got %d neighbors, %s", shape, len(neighbors), data)
+		}
+		want := CLINeighbor{Protocol: "lldp", LocalPort: "Ethernet1", RemoteChassis: "aabbccddee01", RemotePort: "Ethernet49/1", RemoteName: "spine1", RemoteMgt: "10.0.0.2"}
+		if *neighbors[0] != want {
+			t.Errorf("%s: neighbor = %+v", shape, *neighbors[0])
+		}
+
+		data, err = client.Get(gnmiInterfacesPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		interfaces, err := ParseGNMIInterfaces(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(interfaces) != 2 || interfaces[1].Name != "Ethernet1" || interfaces[1].Speed != 100000 || interfaces[2].OperStatus != "down" {
+			t.Errorf("interfaces = %+v %+v", interfaces[1], interfaces[2])
+		}
+		client.Close()
+	}
+}
+
+var testGNMISystem = []*gnmi.Notification{{
+	Prefix: testPath(elem("openconfig-system:system"), elem("state")),
+	Update: []*gnmi.Update{
+		{Path: testPath(elem("hostname")), Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_StringVal{StringVal: "leaf1"}}},
+		{Path: testPath(elem("domain-name")), Val: &gnmi.TypedValue{Value: &gnmi.TypedValue_StringVal{StringVal: "example.com"}}},
+	},
+}}
+
+func TestGNMICollector(t *testing.T) {
+	port := startGNMIServer(t, map[string][]*gnmi.Notification{
+		"/interfaces":   testGNMIInterfaces,
+		"/lldp":         testGNMILLDPShapes["elements"],
+		"/system/state": testGNMISystem,
+	})
+	store, err := NewCredentialStore(&Config{
+		SNMP: SNMPCredential{Version: "2c", Community: DefaultCommunity},
+		GNMI: GNMICredential{Port: port, CIDR: []string{"127.0.0.0/8"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collector := &GNMICollector{Credentials: store}
+	node := &NetNode{Mgt: "127.0.0.1"}
+	if !collector.Match(node) {
+		t.Fatal("collector does not match 127.0.0.1")
+	}
+	ids := []string{}
+	neighbors, err := collector.Collect(node, func(id string) { ids = append(ids, id) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != "001122334455" || ids[1] != deviceName("leaf1") {
+		t.Errorf("registered %v", ids)
+	}
+	if len(neighbors) != 1 {
+		t.Fatalf("got %d neighbors", len(neighbors))
+	}
+	neighbor := neighbors[0]
+	if neighbor.RemoteChassis != "aabbccddee01" || neighbor.LocalPort[0] != "Ethernet1" || neighbor.LocalInterface[0] == nil {
+		t.Errorf("neighbor = %+v", *neighbor)
+	}
+}
+
+func TestGNMICollectorNoNeighbor(t *testing.T) {
+	port := startGNMIServer(t, map[string][]*gnmi.Notification{
+		"/interfaces": testGNMIInterfaces,
+		"/lldp": {{Update: []*gnmi.Update{
+			jsonUpdate(testPath(elem("lldp"), elem("state")), `{"chassis-id": "00:11:22:33:44:55"}`),
+		}}},
+	})
+	store, err := NewCredentialStore(&Config{
+		SNMP: SNMPCredential{Version: "2c", Community: DefaultCommunity},
+		GNMI: GNMICredential{Port: port, CIDR: []string{"127.0.0.0/8"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := &GNMICollector{Credentials: store}
+	if _, err := collector.Collect(&NetNode{Mgt: "127.0.0.1"}, func(string) {}); err == nil {
+		t.Error("expected an error when LLDP returns no neighbor")
+	}
+}
+
+/*
+* 为127.0.0.1生成自签名证书，返回服务端证书和CA文件
+ */
+func selfSignedCert(t *testing.T) (tls.Certificate, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gnmi-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	if err := ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, ca
+}
+
+func TestGNMITLS(t *testing.T) {
+	cert, ca := selfSignedCert(t)
+	port := startGNMIServer(t, map[string][]*gnmi.Notification{"/system/state": testGNMISystem},
+		grpc.Creds(credentials.NewServerTLSFromCert(&cert)))
+
+	tests := []struct {
+		credential GNMICredential
+		ok         bool
+	}{
+		{GNMICredential{Port: port, TLS: true, CA: ca}, true},
+		{GNMICredential{Port: port, TLS: true, SkipVerify: true}, true},
+		{GNMICredential{Port: port, TLS: true}, false},
+		{GNMICredential{Port: port}, false},
+	}
+	for i, test := range tests {
+		client, err := DialGNMI("127.0.0.1", &test.credential)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		data, err := client.Get(gnmiSystemPath)
+		client.Close()
+		if test.ok && (err != nil || ParseGNMIHostname(data) != "leaf1") {
+			t.Errorf("%d: hostname %s, %v", i, data, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("%d: expected an error", i)
+		}
+	}
+}
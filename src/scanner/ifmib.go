@@ -0,0 +1,228 @@
+package scanner
+
+import (
+	"strconv"
+	. "util"
+)
+
+const (
+	ifDescr              = "1.3.6.1.2.1.2.2.1.2"
+	ifType               = "1.3.6.1.2.1.2.2.1.3"
+	ifPhysAddress        = "1.3.6.1.2.1.2.2.1.6"
+	ifAdminStatus        = "1.3.6.1.2.1.2.2.1.7"
+	ifOperStatus         = "1.3.6.1.2.1.2.2.1.8"
+	ifHighSpeed          = "1.3.6.1.2.1.31.1.1.1.15"
+	ifAlias              = "1.3.6.1.2.1.31.1.1.1.18"
+	lldpLocPortIDSubtype = "1.0.8802.1.1.2.1.3.7.1.2"
+	lldpLocPortDesc      = "1.0.8802.1.1.2.1.3.7.1.4"
+)
+
+var ifOperStatusName = map[int]string{
+	1: "up",
+	2: "down",
+	3: "testing",
+	4: "unknown",
+	5: "dormant",
+	6: "notPresent",
+	7: "lowerLayerDown",
+}
+
+var ifAdminStatusName = map[int]string{
+	1: "up",
+	2: "down",
+	3: "testing",
+}
+
+/*
+* 读取IF-MIB中的接口名称、描述、速率和状态，返回ifIndex到接口的映射
+ */
+func (n *NetNodeHandler) Interfaces() (map[int64]*NetInterface, error) {
+	result := make(map[int64]*NetInterface)
+
+	names, err := n.walkOctetString(ifName)
+	if err != nil {
+		return nil, err
+	}
+	descrs, err := n.walkOctetString(ifDescr)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := n.walkOctetString(ifAlias)
+	if err != nil {
+		return nil, err
+	}
+	types, err := n.walkInteger(ifType)
+	if err != nil {
+		return nil, err
+	}
+	speeds, err := n.walkInteger(ifHighSpeed)
+	if err != nil {
+		return nil, err
+	}
+	opers, err := n.walkInteger(ifOperStatus)
+	if err != nil {
+		return nil, err
+	}
+	admins, err := n.walkInteger(ifAdminStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	get := func(index string) *NetInterface {
+		i, err := strconv.ParseInt(index, 10, 64)
+		if err != nil {
+			return nil
+		}
+		if _, ok := result[i]; !ok {
+			result[i] = &NetInterface{Index: i}
+		}
+		return result[i]
+	}
+
+	for index, value := range descrs {
+		if intf := get(index); intf != nil {
+			intf.Descr = string(value)
+		}
+	}
+	for index, value := range names {
+		if intf := get(index); intf != nil {
+			intf.Name = string(value)
+		}
+	}
+	for index, value := range aliases {
+		if intf := get(index); intf != nil {
+			intf.Alias = string(value)
+		}
+	}
+	for index, value := range types {
+		if intf := get(index); intf != nil {
+			intf.Type = value
+		}
+	}
+	for index, value := range speeds {
+		if intf := get(index); intf != nil {
+			intf.Speed = int64(value)
+		}
+	}
+	for index, value := range opers {
+		if intf := get(index); intf != nil {
+			intf.OperStatus = ifOperStatusName[value]
+		}
+	}
+	for index, value := range admins {
+		if intf := get(index); intf != nil {
+			intf.AdminStatus = ifAdminStatusName[value]
+		}
+	}
+
+	for _, intf := range result {
+		if intf.Name == "" {
+			intf.Name = intf.Descr
+		}
+	}
+	return result, nil
+}
+
+/*
+* 将lldpLocPortNum对应到ifIndex，portids为LocalPort的结果。
+* 返回lldpLocPortNum到ifIndex以及port id到ifIndex的映射。
+* 厂商模板指定lldpLocPortNum等于ifIndex时直接使用，否则依次使用port id、port描述
+* 与ifName、ifDescr匹配，都不匹配时如果lldpLocPortNum本身是存在的ifIndex则直接使用
+ */
+func (n *NetNodeHandler) LocalPortIfIndex(portids map[string]string, interfaces map[int64]*NetInterface) (map[string]int64, map[string]int64, error) {
+	byport := make(map[string]int64)
+	byid := make(map[string]int64)
+
+	subtypes, err := n.walkInteger(n.profile.LocPortSubtypeOID)
+	if err != nil {
+		return nil, nil, err
+	}
+	descs, err := n.walkOctetString(n.profile.LocPortDescOID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := make(map[string]int64, len(interfaces)*2)
+	for index, intf := range interfaces {
+		if intf.Descr != "" {
+			names[intf.Descr] = index
+		}
+		if intf.Name != "" {
+			names[intf.Name] = index
+		}
+	}
+
+	for portnum, value := range portids {
+		id := decodePortID(subtypes[portnum], []byte(value)).Value
+		if n.profile.PortNumIsIfIndex {
+			if index, err := strconv.ParseInt(portnum, 10, 64); err == nil {
+				if _, ok := interfaces[index]; ok {
+					byport[portnum], byid[id] = index, index
+					continue
+				}
+			}
+		}
+		if index, ok := names[id]; ok {
+			byport[portnum], byid[id] = index, index
+			continue
+		}
+		if index, ok := names[string(descs[portnum])]; ok {
+			byport[portnum], byid[id] = index, index
+			continue
+		}
+		if index, err := strconv.ParseInt(portnum, 10, 64); err == nil {
+			if _, ok := interfaces[index]; ok {
+				byport[portnum], byid[id] = index, index
+			}
+		}
+	}
+	return byport, byid, nil
+}
+
+/*
+* 生成端口到ifIndex的映射，包含ifName、ifDescr以及LLDP通告的port id
+ */
+func portIndex(interfaces map[int64]*NetInterface, portids map[string]int64) map[string]int64 {
+	result := make(map[string]int64, len(interfaces)*2+len(portids))
+	for id, index := range portids {
+		result[id] = index
+	}
+	for index, intf := range interfaces {
+		if intf.Descr != "" {
+			result[intf.Descr] = index
+		}
+		if intf.Name != "" {
+			result[intf.Name] = index
+		}
+	}
+	return result
+}
+
+/*
+* 生成LINK_TO关系上一端的接口属性，prefix为"l"或"r"。
+* neo4j的数组不能包含null，找不到的接口使用空值
+ */
+func interfaceProps(prefix string, interfaces []*NetInterface) map[string]interface{} {
+	names := make([]string, len(interfaces))
+	descrs := make([]string, len(interfaces))
+	aliases := make([]string, len(interfaces))
+	speeds := make([]int64, len(interfaces))
+	opers := make([]string, len(interfaces))
+	for i, intf := range interfaces {
+		if intf == nil {
+			continue
+		}
+		names[i] = intf.Name
+		descrs[i] = intf.Descr
+		aliases[i] = intf.Alias
+		speeds[i] = intf.Speed
+		opers[i] = intf.OperStatus
+	}
+	return map[string]interface{}{
+		prefix + "ifnames": names,
+		prefix + "descrs":  descrs,
+		prefix + "aliases": aliases,
+		prefix + "speeds":  speeds,
+		prefix + "opers":   opers,
+	}
+}
@@ -0,0 +1,187 @@
+package scanner
+
+import (
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+	. "util"
+)
+
+const (
+	ospfRouterId      = "1.3.6.1.2.1.14.1.1.0"
+	ospfIfAreaId      = "1.3.6.1.2.1.14.7.1.3"
+	ospfIfMetricValue = "1.3.6.1.2.1.14.8.1.4"
+	ospfNbrRtrId      = "1.3.6.1.2.1.14.10.1.3"
+	ospfNbrState      = "1.3.6.1.2.1.14.10.1.6"
+
+	isisSysID               = "1.3.6.1.2.1.138.1.1.1.3.0"
+	isisCircIfIndex         = "1.3.6.1.2.1.138.1.3.2.1.2"
+	isisCircLevelMetric     = "1.3.6.1.2.1.138.1.4.1.1.2"
+	isisCircLevelWideMetric = "1.3.6.1.2.1.138.1.4.1.1.3"
+	isisISAdjState          = "1.3.6.1.2.1.138.1.6.1.1.2"
+	isisISAdjNeighSysID     = "1.3.6.1.2.1.138.1.6.1.1.6"
+	isisISAdjUsage          = "1.3.6.1.2.1.138.1.6.1.1.8"
+)
+
+var ospfNbrStateName = map[int]string{
+	1: "down",
+	2: "attempt",
+	3: "init",
+	4: "twoWay",
+	5: "exchangeStart",
+	6: "exchange",
+	7: "loading",
+	8: "full",
+}
+
+var isisAdjStateName = map[int]string{
+	1: "down",
+	2: "initializing",
+	3: "up",
+	4: "failed",
+}
+
+var isisLevelName = map[int]string{
+	1: "level1",
+	2: "level2",
+	3: "level1and2",
+}
+
+/*
+* 读取OSPF邻居，通过邻居地址所在的本端OSPF接口得到区域、开销和本端端口。
+* ospfIfTable和ospfNbrTable的索引为地址.无编号接口的ifIndex
+ */
+func (n *NetNodeHandler) OSPFNeighbors(addresses []*NetAddress) ([]*NetIGPAdj, error) {
+	result := []*NetIGPAdj{}
+
+	resp, err := n.snmpd.Get([]string{ospfRouterId})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Variables) > 0 {
+		n.node.OSPFRouterID = pduAddress(resp.Variables[0])
+	}
+
+	states, err := n.walkInteger(ospfNbrState)
+	if err != nil || len(states) == 0 {
+		return result, err
+	}
+	rtrids, err := n.walkAddress(ospfNbrRtrId)
+	if err != nil {
+		return nil, err
+	}
+	areas, err := n.walkAddress(ospfIfAreaId)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := n.walkInteger(ospfIfMetricValue)
+	if err != nil {
+		return nil, err
+	}
+
+	for index, state := range states {
+		parts := strings.Split(index, ".")
+		if len(parts) != 5 {
+			continue
+		}
+		nbr := strings.Join(parts[:4], ".")
+		adj := &NetIGPAdj{
+			Protocol:     "ospf",
+			NeighborAddr: nbr,
+			NeighborID:   rtrids[index],
+			State:        ospfNbrStateName[state],
+		}
+
+		// 无编号接口直接使用ifIndex，否则找到与邻居在同一网段的本端地址
+		ifindex, _ := strconv.ParseInt(parts[4], 10, 64)
+		local := "0.0.0.0." + parts[4]
+		if ifindex == 0 {
+			ip := net.ParseIP(nbr)
+			for _, addr := range addresses {
+				_, ipnet, err := net.ParseCIDR(addr.IP + "/" + strconv.Itoa(addr.PrefixLen))
+				if err == nil && addr.PrefixLen < 32 && ipnet.Contains(ip) {
+					ifindex = addr.IfIndex
+					local = addr.IP + ".0"
+					break
+				}
+			}
+		}
+		adj.Area = areas[local]
+		adj.Metric = int64(metrics[local+".0"])
+		adj.LocalPort = interfaceName(n.node, ifindex)
+		result = append(result, adj)
+	}
+	return result, nil
+}
+
+/*
+* 读取IS-IS邻居，isisISAdjTable的索引为isisCircIndex.isisISAdjIndex，
+* 开销优先使用wide metric
+ */
+func (n *NetNodeHandler) ISISNeighbors() ([]*NetIGPAdj, error) {
+	result := []*NetIGPAdj{}
+
+	resp, err := n.snmpd.Get([]string{isisSysID})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Variables) > 0 {
+		if sysid := pduBytes(resp.Variables[0]); len(sysid) == 6 {
+			n.node.ISISSysID = hex.EncodeToString(sysid)
+		}
+	}
+
+	states, err := n.walkInteger(isisISAdjState)
+	if err != nil || len(states) == 0 {
+		return result, err
+	}
+	sysids, err := n.walkOctetString(isisISAdjNeighSysID)
+	if err != nil {
+		return nil, err
+	}
+	usages, err := n.walkInteger(isisISAdjUsage)
+	if err != nil {
+		return nil, err
+	}
+	circuits, err := n.walkInteger(isisCircIfIndex)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := n.walkInteger(isisCircLevelMetric)
+	if err != nil {
+		return nil, err
+	}
+	widemetrics, err := n.walkInteger(isisCircLevelWideMetric)
+	if err != nil {
+		return nil, err
+	}
+
+	for index, state := range states {
+		parts := strings.Split(index, ".")
+		if len(parts) != 2 {
+			continue
+		}
+		circ, usage := parts[0], usages[index]
+
+		// level1and2的邻居使用level2的开销
+		level := usage
+		if level == 3 {
+			level = 2
+		}
+		metric := widemetrics[circ+"."+strconv.Itoa(level)]
+		if metric == 0 {
+			metric = metrics[circ+"."+strconv.Itoa(level)]
+		}
+
+		result = append(result, &NetIGPAdj{
+			Protocol:   "isis",
+			NeighborID: hex.EncodeToString(sysids[index]),
+			Level:      isisLevelName[usage],
+			Metric:     int64(metric),
+			State:      isisAdjStateName[state],
+			LocalPort:  interfaceName(n.node, int64(circuits[circ])),
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,184 @@
+package scanner
+
+import (
+	"fmt"
+	"github.com/gosnmp"
+	"net"
+	"strconv"
+	"strings"
+	. "util"
+)
+
+const (
+	ipAdEntIfIndex = "1.3.6.1.2.1.4.20.1.2"
+	ipAdEntNetMask = "1.3.6.1.2.1.4.20.1.3"
+
+	ipAddressIfIndex = "1.3.6.1.2.1.4.34.1.3"
+	ipAddressType    = "1.3.6.1.2.1.4.34.1.4"
+	ipAddressPrefix  = "1.3.6.1.2.1.4.34.1.5"
+
+	ipAddressTypeUnicast = 1
+)
+
+/*
+* 读取ipAddrTable中的IPv4接口地址
+ */
+func (n *NetNodeHandler) IPAddrTable() ([]*NetAddress, error) {
+	result := []*NetAddress{}
+
+	ifindexes, err := n.walkInteger(ipAdEntIfIndex)
+	if err != nil {
+		return nil, err
+	}
+	masks, err := n.walkAddress(ipAdEntNetMask)
+	if err != nil {
+		return nil, err
+	}
+
+	for addr, ifindex := range ifindexes {
+		ip := net.ParseIP(addr).To4()
+		if ip == nil {
+			continue
+		}
+		prefixlen := 32
+		if mask := net.ParseIP(masks[addr]).To4(); mask != nil {
+			prefixlen, _ = net.IPMask(mask).Size()
+		}
+		result = append(result, &NetAddress{
+			IfIndex:   int64(ifindex),
+			Port:      interfaceName(n.node, int64(ifindex)),
+			IP:        ip.String(),
+			PrefixLen: prefixlen,
+		})
+	}
+	return result, nil
+}
+
+/*
+* 解析ipAddressTable的索引：ipAddressAddrType.长度.地址，
+* ipv4z和ipv6z的地址后面带有4字节的zone index
+ */
+func ipAddressIndex(index string) (net.IP, error) {
+	parts := strings.Split(index, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid ipAddressTable index %s", index)
+	}
+	size := 0
+	switch parts[0] {
+	case "1", "3":
+		size = net.IPv4len
+	case "2", "4":
+		size = net.IPv6len
+	default:
+		return nil, fmt.Errorf("unknown address type in index %s", index)
+	}
+	if len(parts) < 2+size {
+		return nil, fmt.Errorf("invalid ipAddressTable index %s", index)
+	}
+	ip := oidBytes(parts[2 : 2+size])
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address in index %s", index)
+	}
+	return net.IP(ip), nil
+}
+
+/*
+* ipAddressPrefix指向ipAddressPrefixTable中的一行，最后一位为前缀长度，
+* 没有前缀信息时为0.0
+ */
+func ipAddressPrefixLen(pdu gosnmp.SnmpPDU) int {
+	if pdu.Type != gosnmp.ObjectIdentifier {
+		return 0
+	}
+	oid := pdu.Value.(string)
+	if strings.Trim(oid, ".") == "0.0" {
+		return 0
+	}
+	prefixlen, _ := strconv.Atoi(oid[strings.LastIndex(oid, ".")+1:])
+	return prefixlen
+}
+
+/*
+* 读取ipAddressTable中的单播地址，跳过IPv6链路本地地址
+ */
+func (n *NetNodeHandler) IPAddressTable() ([]*NetAddress, error) {
+	result := []*NetAddress{}
+
+	ifindexes, err := n.walkInteger(ipAddressIfIndex)
+	if err != nil {
+		return nil, err
+	}
+	types, err := n.walkInteger(ipAddressType)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := n.snmpd.BulkWalkAll(ipAddressPrefix)
+	if err != nil {
+		return nil, err
+	}
+	prefixes := make(map[string]int, len(resp))
+	for _, pdu := range resp {
+		prefixes[oidIndex(pdu.Name, ipAddressPrefix)] = ipAddressPrefixLen(pdu)
+	}
+
+	for index, ifindex := range ifindexes {
+		if t, ok := types[index]; ok && t != ipAddressTypeUnicast {
+			continue
+		}
+		ip, err := ipAddressIndex(index)
+		if err != nil || ip.IsLinkLocalUnicast() {
+			continue
+		}
+		prefixlen := prefixes[index]
+		if prefixlen == 0 {
+			prefixlen = len(ip) * 8
+		}
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4
+			if prefixlen > 32 {
+				prefixlen = 32
+			}
+		}
+		result = append(result, &NetAddress{
+			IfIndex:   int64(ifindex),
+			Port:      interfaceName(n.node, int64(ifindex)),
+			IP:        ip.String(),
+			PrefixLen: prefixlen,
+		})
+	}
+	return result, nil
+}
+
+/*
+* 合并ipAddressTable和ipAddrTable的结果。很多设备的ipAddressTable中IPv4地址
+* 没有前缀信息，此时使用ipAddrTable中的掩码
+ */
+func (n *NetNodeHandler) IPAddresses() ([]*NetAddress, error) {
+	addresses, err := n.IPAddressTable()
+	if err != nil {
+		addresses = []*NetAddress{}
+	}
+
+	legacy, lerr := n.IPAddrTable()
+	if lerr != nil {
+		if err != nil {
+			return nil, lerr
+		}
+		return addresses, nil
+	}
+
+	byip := make(map[string]*NetAddress, len(addresses))
+	for _, addr := range addresses {
+		byip[addr.IP] = addr
+	}
+	for _, addr := range legacy {
+		if exist, ok := byip[addr.IP]; ok {
+			if exist.PrefixLen == 32 {
+				exist.PrefixLen = addr.PrefixLen
+			}
+			continue
+		}
+		addresses = append(addresses, addr)
+	}
+	return addresses, nil
+}
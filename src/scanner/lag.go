@@ -0,0 +1,163 @@
+package scanner
+
+import (
+	"strconv"
+	"strings"
+	. "util"
+)
+
+const (
+	dot3adAggPortSelectedAggID = "1.2.840.10006.300.43.1.2.1.1.12"
+	dot3adAggPortAttachedAggID = "1.2.840.10006.300.43.1.2.1.1.13"
+	ifStackStatus              = "1.3.6.1.2.1.31.1.2.1.3"
+	ifTypeIeee8023adLag        = 161
+)
+
+/*
+* 厂商私有的链路聚合成员表，索引为成员端口的ifIndex，值为聚合接口的ifIndex，
+* 不在聚合中的端口值为0或自身的ifIndex
+ */
+type LagTable struct {
+	GroupIfIndex string
+}
+
+// CISCO-PAGP-MIB pagpGroupIfIndex，包含静态(mode on)、PAgP和LACP的EtherChannel
+var ciscoPagpTable = &LagTable{
+	GroupIfIndex: "1.3.6.1.4.1.9.9.98.1.1.1.1.8",
+}
+
+/*
+* 读取链路聚合成员关系，返回聚合接口ifIndex到链路聚合的映射。
+* 优先使用IEEE8023-LAG-MIB，成员端口的AttachedAggID不为0时表示已加入聚合；
+* LAG-MIB只包含LACP聚合，静态聚合由厂商模板的LagTable以及
+* IF-MIB ifStackTable补充，同时补充未实现LAG-MIB或未被选中的成员端口
+ */
+func (n *NetNodeHandler) Lags(interfaces map[int64]*NetInterface) (map[int64]*NetLag, error) {
+	result := make(map[int64]*NetLag)
+
+	selected, err := n.walkInteger(dot3adAggPortSelectedAggID)
+	if err != nil {
+		return nil, err
+	}
+	attached, err := n.walkInteger(dot3adAggPortAttachedAggID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := map[int64]bool{}
+	add := func(agg, member int64, bundled bool) {
+		if agg == 0 || agg == member || members[member] {
+			return
+		}
+		if _, ok := result[agg]; !ok {
+			result[agg] = &NetLag{Index: agg, Members: []*NetLagMember{}}
+		}
+		result[agg].Members = append(result[agg].Members, &NetLagMember{Index: member, Bundled: bundled})
+		members[member] = true
+	}
+
+	for port, agg := range attached {
+		member, err := strconv.ParseInt(port, 10, 64)
+		if err != nil {
+			continue
+		}
+		add(int64(agg), member, true)
+	}
+	for port, agg := range selected {
+		member, err := strconv.ParseInt(port, 10, 64)
+		if err != nil {
+			continue
+		}
+		add(int64(agg), member, attached[port] == agg)
+	}
+
+	// 厂商表和ifStackTable中没有聚合状态，成员端口up时认为已加入聚合
+	up := func(member int64) bool {
+		intf, ok := interfaces[member]
+		return ok && intf.OperStatus == "up"
+	}
+
+	if n.profile != nil && n.profile.LagTable != nil {
+		group, err := n.walkInteger(n.profile.LagTable.GroupIfIndex)
+		if err != nil {
+			return nil, err
+		}
+		for port, agg := range group {
+			member, err := strconv.ParseInt(port, 10, 64)
+			if err != nil {
+				continue
+			}
+			add(int64(agg), member, up(member))
+		}
+	}
+
+	stack, err := n.walkInteger(ifStackStatus)
+	if err != nil {
+		return nil, err
+	}
+	for index := range stack {
+		parts := strings.Split(index, ".")
+		if len(parts) != 2 {
+			continue
+		}
+		higher, err1 := strconv.ParseInt(parts[0], 10, 64)
+		lower, err2 := strconv.ParseInt(parts[1], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if intf, ok := interfaces[higher]; !ok || intf.Type != ifTypeIeee8023adLag {
+			continue
+		}
+		add(higher, lower, up(lower))
+	}
+
+	return result, nil
+}
+
+/*
+* 生成LINK_TO关系上一端的链路聚合属性：聚合接口名称、全部成员端口、
+* 已加入聚合且up的成员端口带宽之和(Mbps)以及down或未加入聚合的成员端口
+ */
+func lagProps(prefix string, node *NetNode, interfaces []*NetInterface) map[string]interface{} {
+	names := []string{}
+	members := []string{}
+	faulty := []string{}
+	bandwidth := int64(0)
+
+	seen := map[int64]bool{}
+	for _, intf := range interfaces {
+		if intf == nil || node == nil {
+			continue
+		}
+		lag := node.Lag(intf.Index)
+		if lag == nil || seen[lag.Index] {
+			continue
+		}
+		seen[lag.Index] = true
+		names = append(names, interfaceName(node, lag.Index))
+
+		for _, member := range lag.Members {
+			name := interfaceName(node, member.Index)
+			members = append(members, name)
+			if m, ok := node.Interfaces[member.Index]; ok && member.Bundled && m.OperStatus == "up" {
+				bandwidth += m.Speed
+			} else {
+				faulty = append(faulty, name)
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		prefix + "lags":      names,
+		prefix + "lagports":  members,
+		prefix + "lagbw":     bandwidth,
+		prefix + "lagfaulty": faulty,
+	}
+}
+
+func interfaceName(node *NetNode, index int64) string {
+	if intf, ok := node.Interfaces[index]; ok && intf.Name != "" {
+		return intf.Name
+	}
+	return strconv.FormatInt(index, 10)
+}
@@ -0,0 +1,57 @@
+package scanner
+
+import (
+	"testing"
+	. "util"
+)
+
+func TestLagsVendorTable(t *testing.T) {
+	discardLog()
+	dir := t.TempDir()
+	writeCapture(t, dir, "10.0.2.1",
+		"1.3.6.1.2.1.1.2.0|6|1.3.6.1.4.1.9.1.516",
+		"1.3.6.1.2.1.1.5.0|4|sw1",
+		// Gi1/0/4通过LACP加入Po11
+		"1.2.840.10006.300.43.1.2.1.1.12.4|2|11",
+		"1.2.840.10006.300.43.1.2.1.1.13.4|2|11",
+		// Gi1/0/1、Gi1/0/2静态加入Po10，Gi1/0/3不在聚合中
+		"1.3.6.1.4.1.9.9.98.1.1.1.1.8.1|2|10",
+		"1.3.6.1.4.1.9.9.98.1.1.1.1.8.2|2|10",
+		"1.3.6.1.4.1.9.9.98.1.1.1.1.8.3|2|3",
+		"1.3.6.1.4.1.9.9.98.1.1.1.1.8.4|2|11")
+
+	source, err := NewReplaySource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodehandler, err := source.Dial(&NetNode{Mgt: "10.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	interfaces := map[int64]*NetInterface{
+		1:  {Index: 1, OperStatus: "up"},
+		2:  {Index: 2, OperStatus: "down"},
+		3:  {Index: 3, OperStatus: "up"},
+		4:  {Index: 4, OperStatus: "up"},
+		10: {Index: 10, Type: ifTypeIeee8023adLag, OperStatus: "up"},
+		11: {Index: 11, Type: ifTypeIeee8023adLag, OperStatus: "up"},
+	}
+	lags, err := nodehandler.Lags(interfaces)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lags) != 2 || lags[10] == nil || lags[11] == nil {
+		t.Fatalf("lags = %v", lags)
+	}
+	members := map[int64]bool{}
+	for _, member := range lags[10].Members {
+		members[member.Index] = member.Bundled
+	}
+	if len(members) != 2 || !members[1] || members[2] {
+		t.Errorf("Po10 members = %v", members)
+	}
+	if len(lags[11].Members) != 1 || lags[11].Members[0].Index != 4 || !lags[11].Members[0].Bundled {
+		t.Errorf("Po11 members = %+v", lags[11].Members[0])
+	}
+}
@@ -0,0 +1,410 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"strings"
+	. "util"
+)
+
+// NETCONF 1.0的消息结束符，hello中只声明base:1.0以避免chunked framing
+const netconfDelimiter = "]]>]]>"
+
+const netconfHello = `<?xml version="1.0" encoding="UTF-8"?>
+<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">
+<capabilities><capability>urn:ietf:params:netconf:base:1.0</capability></capabilities>
+</hello>`
+
+type NetconfSession struct {
+	session   *ssh.Session
+	stdin     io.WriteCloser
+	stdout    *bufio.Reader
+	messageid int
+}
+
+/*
+* 在已登录的SSH连接上打开netconf子系统并交换hello
+ */
+func (c *SSHClient) Netconf() (*NetconfSession, error) {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	if err := session.RequestSubsystem("netconf"); err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	s := &NetconfSession{session: session, stdin: stdin, stdout: bufio.NewReader(stdout)}
+	if _, err := s.read(); err != nil {
+		session.Close()
+		return nil, err
+	}
+	if err := s.write(netconfHello); err != nil {
+		session.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *NetconfSession) write(message string) error {
+	_, err := io.WriteString(s.stdin, message+netconfDelimiter)
+	return err
+}
+
+func (s *NetconfSession) read() ([]byte, error) {
+	var buf strings.Builder
+	for {
+		chunk, err := s.stdout.ReadString('>')
+		buf.WriteString(chunk)
+		if strings.HasSuffix(buf.String(), netconfDelimiter) {
+			return []byte(strings.TrimSuffix(buf.String(), netconfDelimiter)), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+/*
+* 执行一个RPC，返回完整的rpc-reply
+ */
+func (s *NetconfSession) RPC(body string) ([]byte, error) {
+	s.messageid++
+	err := s.write(fmt.Sprintf(`<rpc message-id="%d" xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">%s</rpc>`, s.messageid, body))
+	if err != nil {
+		return nil, err
+	}
+	reply, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	var r struct {
+		Errors []struct {
+			Message string `xml:"error-message"`
+		} `xml:"rpc-error"`
+	}
+	if err := xml.Unmarshal(reply, &r); err != nil {
+		return nil, err
+	}
+	if len(r.Errors) > 0 {
+		return nil, fmt.Errorf("rpc-error: %s", strings.TrimSpace(r.Errors[0].Message))
+	}
+	return reply, nil
+}
+
+func (s *NetconfSession) Close() error {
+	_, _ = s.RPC("<close-session/>")
+	return s.session.Close()
+}
+
+/*
+* NETCONF模板，Parse和ParseLocal为解析rpc-reply的纯函数。
+* ParseLocal读取本端的chassis id和设备名称，LocalRPC为空时解析RPC的结果
+ */
+type NetconfTemplate struct {
+	RPC        string
+	Parse      func(reply []byte) ([]*CLINeighbor, error)
+	LocalRPC   string
+	ParseLocal func(reply []byte) (chassis string, sysname string, err error)
+}
+
+var (
+	openconfigLLDPTemplate = &NetconfTemplate{
+		RPC:        `<get><filter type="subtree"><lldp xmlns="http://openconfig.net/yang/lldp"/></filter></get>`,
+		Parse:      ParseOpenConfigLLDPXML,
+		ParseLocal: ParseOpenConfigLLDPLocalXML,
+	}
+	junosLLDPTemplate = &NetconfTemplate{
+		RPC:        `<get-lldp-neighbors-information/>`,
+		Parse:      ParseJunosLLDPXML,
+		LocalRPC:   `<get-lldp-local-info/>`,
+		ParseLocal: ParseJunosLLDPLocalXML,
+	}
+	huaweiNetconfLLDPTemplate = &NetconfTemplate{
+		RPC:        `<get><filter type="subtree"><lldp xmlns="urn:huawei:yang:huawei-lldp"/></filter></get>`,
+		Parse:      ParseHuaweiLLDPXML,
+		ParseLocal: ParseHuaweiLLDPLocalXML,
+	}
+	// VRP旧版本不支持huawei-lldp，使用私有的vrp命名空间
+	huaweiVRPLLDPTemplate = &NetconfTemplate{
+		RPC:        `<get><filter type="subtree"><lldp xmlns="http://www.huawei.com/netconf/vrp" content-version="1.0" format-version="1.0"/></filter></get>`,
+		Parse:      ParseHuaweiLLDPXML,
+		ParseLocal: ParseHuaweiLLDPLocalXML,
+	}
+)
+
+/*
+* openconfig-lldp的/lldp/interfaces/interface/neighbors
+ */
+func ParseOpenConfigLLDPXML(reply []byte) ([]*CLINeighbor, error) {
+	var r struct {
+		Interfaces []struct {
+			Name      string `xml:"name"`
+			Neighbors []struct {
+				ChassisId         string `xml:"state>chassis-id"`
+				PortId            string `xml:"state>port-id"`
+				SystemName        string `xml:"state>system-name"`
+				ManagementAddress string `xml:"state>management-address"`
+			} `xml:"neighbors>neighbor"`
+		} `xml:"data>lldp>interfaces>interface"`
+	}
+	if err := xml.Unmarshal(reply, &r); err != nil {
+		return nil, err
+	}
+
+	result := []*CLINeighbor{}
+	for _, i := range r.Interfaces {
+		for _, neighbor := range i.Neighbors {
+			result = append(result, &CLINeighbor{
+				Protocol:      "lldp",
+				LocalPort:     i.Name,
+				RemoteChassis: normalizeMac(neighbor.ChassisId),
+				RemotePort:    neighbor.PortId,
+				RemoteName:    neighbor.SystemName,
+				RemoteMgt:     neighbor.ManagementAddress,
+			})
+		}
+	}
+	return result, nil
+}
+
+/*
+* openconfig-lldp的/lldp/state
+ */
+func ParseOpenConfigLLDPLocalXML(reply []byte) (string, string, error) {
+	var r struct {
+		ChassisId  string `xml:"data>lldp>state>chassis-id"`
+		SystemName string `xml:"data>lldp>state>system-name"`
+	}
+	if err := xml.Unmarshal(reply, &r); err != nil {
+		return "", "", err
+	}
+	return normalizeMac(strings.TrimSpace(r.ChassisId)), strings.TrimSpace(r.SystemName), nil
+}
+
+/*
+* Junos的get-lldp-neighbors-information，
+* 较老的版本本端端口为lldp-local-interface
+ */
+func ParseJunosLLDPXML(reply []byte) ([]*CLINeighbor, error) {
+	var r struct {
+		Neighbors []struct {
+			LocalPortId    string `xml:"lldp-local-port-id"`
+			LocalInterface string `xml:"lldp-local-interface"`
+			ChassisId      string `xml:"lldp-remote-chassis-id"`
+			PortId         string `xml:"lldp-remote-port-id"`
+			PortDesc       string `xml:"lldp-remote-port-description"`
+			SystemName     string `xml:"lldp-remote-system-name"`
+		} `xml:"lldp-neighbors-information>lldp-neighbor-information"`
+	}
+	if err := xml.Unmarshal(reply, &r); err != nil {
+		return nil, err
+	}
+
+	result := []*CLINeighbor{}
+	for _, neighbor := range r.Neighbors {
+		local := strings.TrimSpace(neighbor.LocalPortId)
+		if local == "" {
+			local = strings.TrimSpace(neighbor.LocalInterface)
+		}
+		port := strings.TrimSpace(neighbor.PortId)
+		if port == "" {
+			port = strings.TrimSpace(neighbor.PortDesc)
+		}
+		result = append(result, &CLINeighbor{
+			Protocol:      "lldp",
+			LocalPort:     local,
+			RemoteChassis: normalizeMac(strings.TrimSpace(neighbor.ChassisId)),
+			RemotePort:    port,
+			RemoteName:    strings.TrimSpace(neighbor.SystemName),
+		})
+	}
+	return result, nil
+}
+
+/*
+* Junos的get-lldp-local-info
+ */
+func ParseJunosLLDPLocalXML(reply []byte) (string, string, error) {
+	var r struct {
+		ChassisId  string `xml:"lldp-local-info>lldp-local-chassis-id"`
+		SystemName string `xml:"lldp-local-info>lldp-local-system-name"`
+	}
+	if err := xml.Unmarshal(reply, &r); err != nil {
+		return "", "", err
+	}
+	return normalizeMac(strings.TrimSpace(r.ChassisId)), strings.TrimSpace(r.SystemName), nil
+}
+
+/*
+* huawei-lldp的/lldp/interfaces/interface/neighbors，
+* 兼容VRP旧版本lldpInterfaces/lldpInterface/lldpNeighbors的格式
+ */
+func ParseHuaweiLLDPXML(reply []byte) ([]*CLINeighbor, error) {
+	var r struct {
+		Interfaces []struct {
+			Name      string `xml:"name"`
+			Neighbors []struct {
+				ChassisId         string `xml:"chassis-id"`
+				PortId            string `xml:"port-id"`
+				SystemName        string `xml:"system-name"`
+				ManagementAddress string `xml:"management-address>address"`
+			} `xml:"neighbors>neighbor"`
+		} `xml:"data>lldp>interfaces>interface"`
+		VRPInterfaces []struct {
+			Name      string `xml:"ifName"`
+			Neighbors []struct {
+				ChassisId  string `xml:"chassisId"`
+				PortId     string `xml:"portId"`
+				SystemName string `xml:"systemName"`
+			} `xml:"lldpNeighbors>lldpNeighbor"`
+		} `xml:"data>lldp>lldpInterfaces>lldpInterface"`
+	}
+	if err := xml.Unmarshal(reply, &r); err != nil {
+		return nil, err
+	}
+
+	result := []*CLINeighbor{}
+	for _, i := range r.Interfaces {
+		for _, neighbor := range i.Neighbors {
+			result = append(result, &CLINeighbor{
+				Protocol:      "lldp",
+				LocalPort:     i.Name,
+				RemoteChassis: normalizeMac(neighbor.ChassisId),
+				RemotePort:    neighbor.PortId,
+				RemoteName:    neighbor.SystemName,
+				RemoteMgt:     neighbor.ManagementAddress,
+			})
+		}
+	}
+	for _, i := range r.VRPInterfaces {
+		for _, neighbor := range i.Neighbors {
+			result = append(result, &CLINeighbor{
+				Protocol:      "lldp",
+				LocalPort:     i.Name,
+				RemoteChassis: normalizeMac(neighbor.ChassisId),
+				RemotePort:    neighbor.PortId,
+				RemoteName:    neighbor.SystemName,
+			})
+		}
+	}
+	return result, nil
+}
+
+/*
+* huawei-lldp的/lldp/local-info，VRP旧版本为lldpSys/lldpSysInformation
+ */
+func ParseHuaweiLLDPLocalXML(reply []byte) (string, string, error) {
+	var r struct {
+		ChassisId     string `xml:"data>lldp>local-info>chassis-id"`
+		SystemName    string `xml:"data>lldp>local-info>system-name"`
+		VRPChassisId  string `xml:"data>lldp>lldpSys>lldpSysInformation>chassisId"`
+		VRPSystemName string `xml:"data>lldp>lldpSys>lldpSysInformation>sysName"`
+	}
+	if err := xml.Unmarshal(reply, &r); err != nil {
+		return "", "", err
+	}
+	if r.ChassisId == "" && r.SystemName == "" {
+		r.ChassisId, r.SystemName = r.VRPChassisId, r.VRPSystemName
+	}
+	return normalizeMac(strings.TrimSpace(r.ChassisId)), strings.TrimSpace(r.SystemName), nil
+}
+
+/*
+* 通过NETCONF按厂商模板的顺序采集LLDP邻居，使用第一个有结果的模板
+ */
+type NetconfCollector struct {
+	Credentials *CredentialStore
+}
+
+func (c *NetconfCollector) Name() string {
+	return "NETCONF"
+}
+
+func (c *NetconfCollector) Match(netnode *NetNode) bool {
+	return c.Credentials.Netconf(netnode) != nil
+}
+
+func (c *NetconfCollector) Collect(netnode *NetNode, register func(id string)) ([]*NetNeighbor, error) {
+	sshcredential := c.Credentials.Netconf(netnode).SSHCredential
+	if sshcredential.Port == 0 {
+		sshcredential.Port = 830
+	}
+	client, err := DialSSH(netnode.Mgt, &sshcredential)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	session, err := client.Netconf()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	profile := LookupVendorProfile("", netnode)
+	var lasterr error
+	for _, template := range profile.NetconfTemplates {
+		reply, err := session.RPC(template.RPC)
+		if err != nil {
+			lasterr = err
+			continue
+		}
+		neighbors, err := template.Parse(reply)
+		if err != nil {
+			lasterr = err
+			continue
+		}
+		if len(neighbors) > 0 {
+			c.registerLocal(session, template, reply, netnode, register)
+			return cliNetNeighbors(netnode, neighbors), nil
+		}
+	}
+	if lasterr != nil {
+		return nil, lasterr
+	}
+	return nil, fmt.Errorf("No LLDP neighbor returned")
+}
+
+/*
+* 登记本端的chassis id和设备名称，使其他设备通告的邻居可以解析到本设备
+ */
+func (c *NetconfCollector) registerLocal(session *NetconfSession, template *NetconfTemplate, reply []byte, netnode *NetNode, register func(id string)) {
+	if template.ParseLocal == nil {
+		return
+	}
+	if template.LocalRPC != "" {
+		var err error
+		reply, err = session.RPC(template.LocalRPC)
+		if err != nil {
+			Logger.Printf("[%s] NETCONF local LLDP, %v\n", netnode.Mgt, err)
+			return
+		}
+	}
+	chassis, sysname, err := template.ParseLocal(reply)
+	if err != nil {
+		Logger.Printf("[%s] NETCONF local LLDP, %v\n", netnode.Mgt, err)
+		return
+	}
+	if chassis != "" {
+		register(chassis)
+	}
+	if sysname != "" {
+		register(deviceName(sysname))
+	}
+}
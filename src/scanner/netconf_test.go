@@ -0,0 +1,148 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readNetconfFixture(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "netconf", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseNetconf(t *testing.T) {
+	tests := []struct {
+		file     string
+		template *NetconfTemplate
+		want     []CLINeighbor
+	}{
+		{"openconfig_lldp.xml", openconfigLLDPTemplate, []CLINeighbor{
+			{Protocol: "lldp", LocalPort: "Ethernet1", RemoteChassis: "001c73000002", RemotePort: "Ethernet49/1", RemoteName: "spine1", RemoteMgt: "10.0.0.2"},
+			{Protocol: "lldp", LocalPort: "Ethernet2", RemoteChassis: "001c73000003", RemotePort: "Ethernet49/1", RemoteName: "spine2"},
+		}},
+		{"junos_lldp.xml", junosLLDPTemplate, []CLINeighbor{
+			{Protocol: "lldp", LocalPort: "xe-0/0/0", RemoteChassis: "544b8c000001", RemotePort: "513", RemoteName: "qfx2"},
+			{Protocol: "lldp", LocalPort: "ge-0/0/1.0", RemoteChassis: "001122334455", RemotePort: "Gi1/0/1", RemoteName: "sw2"},
+		}},
+		{"huawei_lldp.xml", huaweiNetconfLLDPTemplate, []CLINeighbor{
+			{Protocol: "lldp", LocalPort: "10GE1/0/1", RemoteChassis: "00e0fc222222", RemotePort: "10GE1/0/48", RemoteName: "CE2", RemoteMgt: "10.2.2.2"},
+		}},
+		{"huawei_vrp_lldp.xml", huaweiVRPLLDPTemplate, []CLINeighbor{
+			{Protocol: "lldp", LocalPort: "XGigabitEthernet0/0/1", RemoteChassis: "00e0fc444444", RemotePort: "XGigabitEthernet0/0/2", RemoteName: "S6720-2"},
+		}},
+	}
+
+	for _, test := range tests {
+		got, err := test.template.Parse(readNetconfFixture(t, test.file))
+		if err != nil {
+			t.Errorf("%s: %v", test.file, err)
+			continue
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: got %d neighbors, want %d", test.file, len(got), len(test.want))
+			continue
+		}
+		for i := range got {
+			if *got[i] != test.want[i] {
+				t.Errorf("%s: neighbor %d = %+v, want %+v", test.file, i, *got[i], test.want[i])
+			}
+		}
+	}
+}
+
+func TestParseNetconfLocal(t *testing.T) {
+	tests := []struct {
+		file     string
+		template *NetconfTemplate
+		chassis  string
+		sysname  string
+	}{
+		// openconfig和huawei的本端信息与邻居在同一个rpc-reply中
+		{"openconfig_lldp.xml", openconfigLLDPTemplate, "001c73000001", "leaf1"},
+		{"junos_lldp_local.xml", junosLLDPTemplate, "544b8c000000", "qfx1"},
+		{"huawei_lldp.xml", huaweiNetconfLLDPTemplate, "00e0fc111111", "CE1"},
+		{"huawei_vrp_lldp.xml", huaweiVRPLLDPTemplate, "00e0fc333333", "S6720-1"},
+	}
+
+	for _, test := range tests {
+		chassis, sysname, err := test.template.ParseLocal(readNetconfFixture(t, test.file))
+		if err != nil {
+			t.Errorf("%s: %v", test.file, err)
+			continue
+		}
+		if chassis != test.chassis || sysname != test.sysname {
+			t.Errorf("%s: got %q %q, want %q %q", test.file, chassis, sysname, test.chassis, test.sysname)
+		}
+	}
+
+	if _, err := ParseJunosLLDPXML([]byte("<rpc-reply><lldp-neighbors-information>")); err == nil {
+		t.Error("expected an error for a truncated reply")
+	}
+}
+
+type nopWriteCloser struct {
+	bytes.Buffer
+}
+
+func (w *nopWriteCloser) Close() error {
+	return nil
+}
+
+func TestNetconfRead(t *testing.T) {
+	// 内容中的'>'和]]>不能作为消息的结束
+	stream := `<hello><capabilities/></hello>]]>]]>` +
+		"\n<rpc-reply><data><![CDATA[a]]>b]]></data></rpc-reply>\n]]>]]>" +
+		`<rpc-reply><ok/>`
+	s := &NetconfSession{stdout: bufio.NewReaderSize(strings.NewReader(stream), 16)}
+
+	want := []string{
+		`<hello><capabilities/></hello>`,
+		"\n<rpc-reply><data><![CDATA[a]]>b]]></data></rpc-reply>\n",
+	}
+	for _, w := range want {
+		message, err := s.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(message) != w {
+			t.Errorf("message = %q, want %q", message, w)
+		}
+	}
+	// 连接在消息结束符之前断开
+	if message, err := s.read(); err == nil {
+		t.Errorf("expected an error for an unterminated message, got %q", message)
+	}
+}
+
+func TestNetconfRPC(t *testing.T) {
+	stdin := &nopWriteCloser{}
+	stream := string(readNetconfFixture(t, "junos_lldp_local.xml")) + netconfDelimiter +
+		`<rpc-reply message-id="2" xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">
+<rpc-error><error-type>protocol</error-type><error-tag>operation-not-supported</error-tag>
+<error-message>
+syntax error
+</error-message></rpc-error></rpc-reply>` + netconfDelimiter
+	s := &NetconfSession{stdin: stdin, stdout: bufio.NewReader(strings.NewReader(stream))}
+
+	reply, err := s.RPC(junosLLDPTemplate.LocalRPC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, sysname, _ := ParseJunosLLDPLocalXML(reply); sysname != "qfx1" {
+		t.Errorf("sysname = %q", sysname)
+	}
+	if !strings.HasPrefix(stdin.String(), `<rpc message-id="1"`) || !strings.HasSuffix(stdin.String(), "<get-lldp-local-info/></rpc>"+netconfDelimiter) {
+		t.Errorf("request = %q", stdin.String())
+	}
+
+	if _, err := s.RPC(junosLLDPTemplate.RPC); err == nil || err.Error() != "rpc-error: syntax error" {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -1,6 +1,8 @@
 package scanner
 
 import (
+	"fmt"
+	"strconv"
 	"sync"
 	"time"
 	. "util"
@@ -11,6 +13,80 @@ type NetNeighbor struct {
 	LocalPort  []string
 	RemoteIP   string
 	RemotePort []string
+	RemoteName string
+	// 对端chassis id及子类型，每个对端端口port id的子类型
+	RemoteChassis     string
+	ChassisSubtype    string
+	RemotePortSubtype []string
+	Protocol          []string        // 每个端口的发现协议，lldp、cdp或lldp+cdp
+	RemoteIDs         []string        // 用于查找对端管理地址的标识，按顺序匹配
+	LocalInterface    []*NetInterface // 与LocalPort对应的本端接口，找不到时为nil
+
+	local *NetNode
+}
+
+/*
+* 写入LINK_TO关系的附加属性
+ */
+func (neighbor *NetNeighbor) Props() map[string]interface{} {
+	props := map[string]interface{}{
+		"protocols": neighbor.Protocol,
+		"rname":     neighbor.RemoteName,
+		"rchassis":  neighbor.RemoteChassis,
+		"rctype":    neighbor.ChassisSubtype,
+		"rptypes":   neighbor.RemotePortSubtype,
+	}
+	for k, v := range interfaceProps("l", neighbor.LocalInterface) {
+		props[k] = v
+	}
+	for k, v := range lagProps("l", neighbor.local, neighbor.LocalInterface) {
+		props[k] = v
+	}
+	for k, v := range opticsProps("l", neighbor.LocalInterface) {
+		props[k] = v
+	}
+	for k, v := range vlanProps("l", neighbor.local, neighbor.LocalInterface) {
+		props[k] = v
+	}
+	return props
+}
+
+/*
+* 对端设备扫描完成后，根据对端端口生成LINK_TO关系上对端的属性
+ */
+func (neighbor *NetNeighbor) RemoteProps(remote *NetNode) map[string]interface{} {
+	interfaces := make([]*NetInterface, len(neighbor.RemotePort))
+	for i, port := range neighbor.RemotePort {
+		interfaces[i] = remote.Interface(port)
+	}
+
+	props := interfaceProps("r", interfaces)
+	for k, v := range lagProps("r", remote, interfaces) {
+		props[k] = v
+	}
+	for k, v := range opticsProps("r", interfaces) {
+		props[k] = v
+	}
+
+	// 两端都允许通过的VLAN，以及两端允许的VLAN是否一致
+	lvlans := vlanProps("l", neighbor.local, neighbor.LocalInterface)["lvlans"].([]int64)
+	rvlans := vlanProps("r", remote, interfaces)
+	vlans := []int64{}
+	rset := map[int64]bool{}
+	for _, vlan := range rvlans["rvlans"].([]int64) {
+		rset[vlan] = true
+	}
+	for _, vlan := range lvlans {
+		if rset[vlan] {
+			vlans = append(vlans, vlan)
+		}
+	}
+	for k, v := range rvlans {
+		props[k] = v
+	}
+	props["vlans"] = vlans
+	props["vlanmismatch"] = len(vlans) != len(lvlans) || len(vlans) != len(rset)
+	return props
 }
 
 type NetNeighborScanner struct {
@@ -20,77 +96,530 @@ type NetNeighborScanner struct {
 	UnValidNeighborChan chan *NetNeighbor
 	UnValidNeighbor     *NodeList
 	ValidNeighborChan   chan *NetNeighbor
-	Community           string
+	Collectors          []Collector // 按顺序尝试，使用第一个成功的采集方式采集邻居
 	ScanFinished        bool
 	SaveFinished        sync.WaitGroup
 	SavedCount          int64
 }
 
 func (n *NetNeighborScanner) scanNeighbor(netnode *NetNode) error {
-	var nodehandler *NetNodeHandler
-	nodehandler = NewNetNodeHandler(netnode, n.Community)
-	if err := nodehandler.SNMPConnect(); err != nil {
+	register := func(id string) {
+		n.NetChassisIdChan <- [2]string{id, netnode.Mgt}
+	}
+
+	lasterr := fmt.Errorf("No collector matched")
+	failed := map[Collector]bool{}
+	for _, collector := range n.Collectors {
+		if !collector.Match(netnode) {
+			continue
+		}
+		neighbors, err := collector.Collect(netnode, register)
+		if err != nil {
+			Logger.Printf("[%s] %s, %v\n", netnode.Mgt, collector.Name(), err)
+			failed[collector] = true
+			lasterr = err
+			continue
+		}
+		n.enrich(netnode, collector, failed, neighbors, register)
+		n.publishNeighbor(neighbors)
+		return nil
+	}
+	return lasterr
+}
+
+/*
+* 邻居采集成功后，由其他未失败的Enricher补充设备信息，例如gNMI采集邻居时通过SNMP采集路由、VLAN等
+ */
+func (n *NetNeighborScanner) enrich(netnode *NetNode, collector Collector, failed map[Collector]bool, neighbors []*NetNeighbor, register func(id string)) {
+	for _, other := range n.Collectors {
+		enricher, ok := other.(Enricher)
+		if !ok || other == collector || failed[other] || !other.Match(netnode) {
+			continue
+		}
+		if err := enricher.Enrich(netnode, neighbors, register); err != nil {
+			Logger.Printf("[%s] %s enrich, %v\n", netnode.Mgt, other.Name(), err)
+		}
+	}
+}
+
+/*
+* 通过SNMP采集邻居以及设备、接口、路由等信息
+ */
+type SNMPCollector struct {
+	Dialer     SNMPDialer
+	RouteRoles []string // 采集路由表的设备角色或标签
+}
+
+func (c *SNMPCollector) Name() string {
+	return "SNMP"
+}
+
+func (c *SNMPCollector) Match(netnode *NetNode) bool {
+	return true
+}
+
+func (c *SNMPCollector) Collect(netnode *NetNode, register func(id string)) ([]*NetNeighbor, error) {
+	nodehandler, err := c.Dialer.Dial(netnode)
+	if err != nil {
+		return nil, err
+	}
+	defer nodehandler.SNMPClose()
+
+	if err := c.scanDevice(nodehandler, register); err != nil {
+		return nil, err
+	}
+	interfaces := netnode.Interfaces
+
+	local_port, err := nodehandler.LocalPort()
+	if err != nil {
+		return nil, err
+	}
+
+	local_ifindex, local_portids, err := nodehandler.LocalPortIfIndex(local_port, interfaces)
+	if err != nil {
+		Logger.Printf("[%s] LLDP local port, %v\n", netnode.Mgt, err)
+		local_ifindex, local_portids = map[string]int64{}, map[string]int64{}
+	}
+	netnode.Ports = portIndex(interfaces, local_portids)
+
+	neighbors, err := c.lldpNeighbors(nodehandler, local_port, local_ifindex)
+	if err != nil {
+		return nil, err
+	}
+
+	cdp_neighbors, err := c.cdpNeighbors(nodehandler, neighbors)
+	if err != nil {
+		Logger.Printf("[%s] CDP, %v\n", netnode.Mgt, err)
+	}
+	neighbors = append(neighbors, cdp_neighbors...)
+	for _, neighbor := range neighbors {
+		neighbor.local = netnode
+	}
+
+	c.scanTables(nodehandler, neighbors)
+	return neighbors, nil
+}
+
+/*
+* 邻居由gNMI、NETCONF等采集方式得到时，通过SNMP补充设备、接口、路由等信息，
+* 邻居的本端接口替换为SNMP采集到的接口
+ */
+func (c *SNMPCollector) Enrich(netnode *NetNode, neighbors []*NetNeighbor, register func(id string)) error {
+	nodehandler, err := c.Dialer.Dial(netnode)
+	if err != nil {
 		return err
 	}
 	defer nodehandler.SNMPClose()
 
+	if err := c.scanDevice(nodehandler, register); err != nil {
+		return err
+	}
+	netnode.Ports = portIndex(netnode.Interfaces, nil)
+	for _, neighbor := range neighbors {
+		for i, port := range neighbor.LocalPort {
+			if intf := netnode.Interface(port); intf != nil && i < len(neighbor.LocalInterface) {
+				neighbor.LocalInterface[i] = intf
+			}
+		}
+	}
+
+	c.scanTables(nodehandler, neighbors)
+	return nil
+}
+
+/*
+* 读取设备的模块、系统信息、本端chassis id、接口、光模块以及链路聚合
+ */
+func (c *SNMPCollector) scanDevice(nodehandler *NetNodeHandler, register func(id string)) error {
+	netnode := nodehandler.node
+	entities, err := nodehandler.Entities()
+	if err != nil {
+		Logger.Printf("[%s] ENTITY-MIB, %v\n", netnode.Mgt, err)
+	} else {
+		netnode.Modules = modules(entities)
+	}
+
+	if err := nodehandler.System(); err != nil {
+		Logger.Printf("[%s] System, %v\n", netnode.Mgt, err)
+	} else {
+		netnode.Discrepancies = reconcile(netnode)
+		if netnode.SysName != "" {
+			register(deviceName(netnode.SysName))
+		}
+	}
+
 	self_chassis, err := nodehandler.SelfChassisID()
 	if err != nil {
 		return err
 	}
 	for _, id := range self_chassis {
-		n.NetChassisIdChan <- [2]string{id, nodehandler.node.Mgt}
+		register(id)
+	}
+
+	interfaces, err := nodehandler.Interfaces()
+	if err != nil {
+		Logger.Printf("[%s] IF-MIB, %v\n", netnode.Mgt, err)
+		interfaces = map[int64]*NetInterface{}
+	}
+
+	if entities != nil {
+		if err := nodehandler.Optics(entities, interfaces); err != nil {
+			Logger.Printf("[%s] ENTITY-SENSOR-MIB, %v\n", netnode.Mgt, err)
+		}
 	}
+	netnode.Interfaces = interfaces
 
+	lags, err := nodehandler.Lags(interfaces)
+	if err != nil {
+		Logger.Printf("[%s] LAG, %v\n", netnode.Mgt, err)
+		lags = map[int64]*NetLag{}
+	}
+	netnode.Lags = lags
+	return nil
+}
+
+/*
+* 读取BGP邻居、接口地址、路由表、IGP邻居以及VLAN/FDB/ARP
+ */
+func (c *SNMPCollector) scanTables(nodehandler *NetNodeHandler, neighbors []*NetNeighbor) {
+	netnode := nodehandler.node
+	if peers, err := nodehandler.BGPPeers(); err != nil {
+		Logger.Printf("[%s] BGP, %v\n", netnode.Mgt, err)
+	} else {
+		netnode.BGPPeers = peers
+	}
+
+	if addresses, err := nodehandler.IPAddresses(); err != nil {
+		Logger.Printf("[%s] IP address, %v\n", netnode.Mgt, err)
+	} else {
+		netnode.Addresses = addresses
+	}
+
+	if netnode.MatchRole(c.RouteRoles) {
+		if routes, err := nodehandler.Routes(); err != nil {
+			Logger.Printf("[%s] Route, %v\n", netnode.Mgt, err)
+		} else {
+			netnode.Routes = routes
+		}
+	}
+
+	if netnode.HasLable("BACKBONE") {
+		if err := c.scanIGP(nodehandler); err != nil {
+			Logger.Printf("[%s] IGP, %v\n", netnode.Mgt, err)
+		}
+	}
+
+	if err := c.scanBridge(nodehandler, neighbors); err != nil {
+		Logger.Printf("[%s] VLAN/FDB/ARP, %v\n", netnode.Mgt, err)
+	}
+}
+
+func (c *SNMPCollector) lldpNeighbors(nodehandler *NetNodeHandler, local_port map[string]string, local_ifindex map[string]int64) ([]*NetNeighbor, error) {
 	rem_chassis, err := nodehandler.RemChassisID()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	rem_port, err := nodehandler.RemPort()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	local_port, err := nodehandler.LocalPort()
+	rem_sysname, err := nodehandler.RemSysName()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	rem_manaddr, err := nodehandler.RemManAddr()
+	if err != nil {
+		return nil, err
 	}
 
 	neighbors := map[string]*NetNeighbor{}
 
-	for rem_idx, chassis := range rem_chassis {
+	for rem_idx, chassis_id := range rem_chassis {
+		chassis := chassis_id.Value
 		if _, ok := neighbors[chassis]; !ok {
 			neighbors[chassis] = &NetNeighbor{
-				LocalIP:    nodehandler.node.Mgt,
-				LocalPort:  []string{},
-				RemoteIP:   "",
-				RemotePort: []string{},
+				LocalIP:           nodehandler.node.Mgt,
+				LocalPort:         []string{},
+				RemoteIP:          "",
+				RemotePort:        []string{},
+				RemoteName:        "",
+				RemoteChassis:     chassis,
+				ChassisSubtype:    chassis_id.Subtype,
+				RemotePortSubtype: []string{},
+				Protocol:          []string{},
+				RemoteIDs:         []string{},
+				LocalInterface:    []*NetInterface{},
 			}
 		}
+		// 优先使用对端通告的管理地址和设备名称，chassis id作为最后的匹配方式
+		for _, addr := range rem_manaddr[rem_idx] {
+			neighbors[chassis].addRemoteID(addr)
+		}
+		if sysname, ok := rem_sysname[rem_idx]; ok && neighbors[chassis].RemoteName == "" {
+			neighbors[chassis].RemoteName = sysname
+			neighbors[chassis].addRemoteID(deviceName(sysname))
+		}
 		if localportname, ok := local_port[rem_idx]; ok {
+			var intf *NetInterface
+			if index, ok := local_ifindex[rem_idx]; ok {
+				intf = nodehandler.node.Interfaces[index]
+				localportname = intf.Name
+			}
+			neighbors[chassis].LocalInterface = append(neighbors[chassis].LocalInterface, intf)
 			neighbors[chassis].LocalPort = append(neighbors[chassis].LocalPort, localportname)
-			neighbors[chassis].RemotePort = append(neighbors[chassis].RemotePort, rem_port[rem_idx])
+			neighbors[chassis].RemotePort = append(neighbors[chassis].RemotePort, rem_port[rem_idx].Value)
+			neighbors[chassis].RemotePortSubtype = append(neighbors[chassis].RemotePortSubtype, rem_port[rem_idx].Subtype)
+			neighbors[chassis].Protocol = append(neighbors[chassis].Protocol, "lldp")
 		}
 	}
 
+	result := make([]*NetNeighbor, 0, len(neighbors))
 	for chassis, neighbor := range neighbors {
-		if rem_ip, ok := n.NetChassisId.Get(chassis); ok {
+		neighbor.addRemoteID(chassis)
+		result = append(result, neighbor)
+	}
+	return result, nil
+}
+
+func (neighbor *NetNeighbor) addRemoteID(id string) {
+	for _, v := range neighbor.RemoteIDs {
+		if v == id {
+			return
+		}
+	}
+	neighbor.RemoteIDs = append(neighbor.RemoteIDs, id)
+}
+
+/*
+* 通过CDP发现邻居，本端端口已经由LLDP发现的不再重复生成链路。
+* 对端设备已经由LLDP在其他端口发现时(设备名称或管理地址相同)，端口合并到LLDP的邻居中
+ */
+func (c *SNMPCollector) cdpNeighbors(nodehandler *NetNodeHandler, lldp []*NetNeighbor) ([]*NetNeighbor, error) {
+	cache, err := nodehandler.CDPCache()
+	if err != nil || len(cache) == 0 {
+		return nil, err
+	}
+
+	type portRef struct {
+		neighbor *NetNeighbor
+		idx      int
+	}
+	lldp_ports := map[string]portRef{}
+	lldp_devices := map[string]*NetNeighbor{}
+	for _, neighbor := range lldp {
+		for idx, port := range neighbor.LocalPort {
+			lldp_ports[port] = portRef{neighbor, idx}
+		}
+		for _, id := range neighbor.RemoteIDs {
+			lldp_devices[id] = neighbor
+		}
+	}
+
+	neighbors := map[string]*NetNeighbor{}
+	for _, entry := range cache {
+		ifindex, err := strconv.ParseInt(entry.IfIndex, 10, 64)
+		if err != nil {
+			continue
+		}
+		intf, ok := nodehandler.node.Interfaces[ifindex]
+		if !ok {
+			continue
+		}
+		localportname := intf.Name
+		if ref, ok := lldp_ports[localportname]; ok {
+			ref.neighbor.Protocol[ref.idx] = "lldp+cdp"
+			continue
+		}
+
+		name := deviceName(entry.DeviceID)
+		neighbor, ok := lldp_devices[name]
+		if !ok && entry.Address != "" {
+			neighbor, ok = lldp_devices[entry.Address]
+		}
+		if ok {
+			if entry.Address != "" {
+				neighbor.addRemoteID(entry.Address)
+			}
+			neighbor.addRemoteID(name)
+		} else {
+			if _, ok := neighbors[name]; !ok {
+				ids := []string{}
+				if entry.Address != "" {
+					ids = append(ids, entry.Address)
+				}
+				neighbors[name] = &NetNeighbor{
+					LocalIP:           nodehandler.node.Mgt,
+					LocalPort:         []string{},
+					RemoteIP:          "",
+					RemotePort:        []string{},
+					RemoteName:        entry.DeviceID,
+					RemoteChassis:     "",
+					ChassisSubtype:    "",
+					RemotePortSubtype: []string{},
+					Protocol:          []string{},
+					RemoteIDs:         append(ids, name),
+					LocalInterface:    []*NetInterface{},
+				}
+			}
+			neighbor = neighbors[name]
+		}
+		neighbor.LocalPort = append(neighbor.LocalPort, localportname)
+		neighbor.RemotePort = append(neighbor.RemotePort, entry.DevicePort)
+		neighbor.RemotePortSubtype = append(neighbor.RemotePortSubtype, "ifName")
+		neighbor.Protocol = append(neighbor.Protocol, "cdp")
+		neighbor.LocalInterface = append(neighbor.LocalInterface, intf)
+	}
+
+	result := make([]*NetNeighbor, 0, len(neighbors))
+	for _, neighbor := range neighbors {
+		result = append(result, neighbor)
+	}
+	return result, nil
+}
+
+/*
+* 读取骨干设备的OSPF和IS-IS邻居
+ */
+func (c *SNMPCollector) scanIGP(nodehandler *NetNodeHandler) error {
+	ospf, err := nodehandler.OSPFNeighbors(nodehandler.node.Addresses)
+	if err != nil {
+		return err
+	}
+
+	isis, err := nodehandler.ISISNeighbors()
+	if err != nil {
+		return err
+	}
+
+	nodehandler.node.IGPAdjs = append(ospf, isis...)
+	return nil
+}
+
+/*
+* 读取接口的VLAN，以及MAC地址表和ARP表，用于确定接入在边缘端口上的终端
+ */
+func (c *SNMPCollector) scanBridge(nodehandler *NetNodeHandler, neighbors []*NetNeighbor) error {
+	baseport, err := nodehandler.BasePortIfIndex()
+	if err != nil {
+		return err
+	}
+
+	if err := nodehandler.Vlans(baseport, nodehandler.node.Interfaces); err != nil {
+		return err
+	}
+
+	fdb, err := nodehandler.Fdb(baseport)
+	if err != nil {
+		return err
+	}
+	nodehandler.node.Endpoints = edgeEndpoints(nodehandler.node, neighbors, fdb)
+
+	arp, err := nodehandler.Arp()
+	if err != nil {
+		return err
+	}
+	nodehandler.node.Arp = arp
+	return nil
+}
+
+func (n *NetNeighborScanner) publishNeighbor(neighbors []*NetNeighbor) {
+	for _, neighbor := range neighbors {
+		// 对端没有可用的标识，无法解析也无法记录为未解析的邻居
+		if len(neighbor.RemoteIDs) == 0 {
+			Logger.Printf("[%s] neighbor without remote id, %v\n", neighbor.LocalIP, neighbor.LocalPort)
+			continue
+		}
+		if rem_ip, ok := n.resolve(neighbor); ok {
 			neighbor.RemoteIP = rem_ip
 			n.ValidNeighborChan <- neighbor
 		} else {
-			neighbor.RemoteIP = chassis
+			neighbor.RemoteIP = neighbor.RemoteIDs[0]
 			n.UnValidNeighborChan <- neighbor
 		}
 	}
+}
+
+/*
+* 依次使用邻居的标识查找对端管理地址
+ */
+func (n *NetNeighborScanner) resolve(neighbor *NetNeighbor) (string, bool) {
+	for _, id := range neighbor.RemoteIDs {
+		if rem_ip, ok := n.NetChassisId.Get(id); ok {
+			return rem_ip, true
+		}
+	}
+	return "", false
+}
 
-	return nil
+/*
+* 预先登记资产信息中的管理地址和设备名称，CDP等协议可直接通过地址或名称找到对端
+ */
+func (n *NetNeighborScanner) registerNetNodes() {
+	for _, node := range n.NetNodes {
+		n.NetChassisId.Set(node.Mgt, node.Mgt)
+		if node.Oobmgt != "" {
+			n.NetChassisId.Set(node.Oobmgt, node.Mgt)
+		}
+		if node.Name != "" {
+			n.NetChassisId.Set(deviceName(node.Name), node.Mgt)
+		}
+	}
+}
+
+/*
+* 建立设备的SNMP连接并确认设备可用。实时扫描使用LiveDialer，离线回放使用ReplaySource
+ */
+type SNMPDialer interface {
+	Dial(netnode *NetNode) (*NetNodeHandler, error)
+}
+
+/*
+* 按顺序尝试设备的认证信息，记录第一个被设备接受的认证信息
+ */
+type LiveDialer struct {
+	Credentials *CredentialStore
+	Recorder    *Recorder // 不为空时记录设备返回的PDU
+}
+
+func (d *LiveDialer) Dial(netnode *NetNode) (*NetNodeHandler, error) {
+	credentials := d.Credentials.Select(netnode)
+	if len(credentials) == 0 {
+		return nil, fmt.Errorf("No credential matched")
+	}
+
+	var lasterr error
+	for _, credential := range credentials {
+		nodehandler, err := NewNetNodeHandler(netnode, &credential.SNMPCredential)
+		if err != nil {
+			lasterr = fmt.Errorf("[%s] %v", credential.Name, err)
+			continue
+		}
+		if d.Recorder != nil {
+			nodehandler.snmpd = d.Recorder.wrap(netnode.Mgt, nodehandler.snmpd)
+		}
+		if err := nodehandler.SNMPConnect(); err != nil {
+			lasterr = fmt.Errorf("[%s] %v", credential.Name, err)
+			continue
+		}
+		if err := nodehandler.Probe(); err != nil {
+			_ = nodehandler.SNMPClose()
+			lasterr = fmt.Errorf("[%s] %v", credential.Name, err)
+			continue
+		}
+		netnode.Credential = credential.Name
+		return nodehandler, nil
+	}
+	return nil, lasterr
 }
 
 func (n *NetNeighborScanner) GenerateNeighbor() {
 	maxThread := 500
 	threadchan := make(chan struct{}, maxThread)
 	wait := sync.WaitGroup{}
+	n.registerNetNodes()
 	for _, netnode := range n.NetNodes {
 		threadchan <- struct{}{}
 		wait.Add(1)
@@ -123,7 +652,7 @@ func (n *NetNeighborScanner) ReadChannel() {
 		var mutex sync.Mutex
 		for {
 			neighbor := <-n.UnValidNeighborChan
-			if rem_ip, ok := n.NetChassisId.Get(neighbor.RemoteIP); ok {
+			if rem_ip, ok := n.resolve(neighbor); ok {
 				neighbor.RemoteIP = rem_ip
 				n.ValidNeighborChan <- neighbor
 			} else {
@@ -152,7 +681,7 @@ func (n *NetNeighborScanner) ReadChannel() {
 					break
 				}
 				neighbor = current.El
-				if rem_ip, ok := n.NetChassisId.Get(neighbor.RemoteIP); ok {
+				if rem_ip, ok := n.resolve(neighbor); ok {
 					neighbor.RemoteIP = rem_ip
 
 					mutex.Lock()
@@ -170,6 +699,10 @@ func (n *NetNeighborScanner) ReadChannel() {
 			//fmt.Printf("Current Lenght of chain: %d.\n", Counter)
 			//mutex.Unlock()
 			if n.ScanFinished && epoch > 4 {
+				for current := n.UnValidNeighbor.Next; current.El != nil; current = current.Next {
+					Logger.Printf("[%s]Unresolved neighbor %s(%s), ids: %v\n", current.El.LocalIP,
+						current.El.RemoteName, current.El.RemoteIP, current.El.RemoteIDs)
+				}
 				break
 			}
 		}
@@ -2,80 +2,288 @@ package scanner
 
 import (
 	"encoding/hex"
+	"fmt"
 	"github.com/gosnmp"
+	"net"
+	"strconv"
 	"strings"
 	"time"
 	. "util"
 )
 
 const (
-	lldpLocChassisID      = "1.0.8802.1.1.2.1.3.2.0"
-	lldpLocChassisIDNexus = "1.3.6.1.2.1.2.2.1.6"
-	lldpRemChassisID      = "1.0.8802.1.1.2.1.4.1.1.5"
-	lldpRemPortID         = "1.0.8802.1.1.2.1.4.1.1.7"
-	lldpLocPortID         = "1.0.8802.1.1.2.1.3.7.1.3"
+	lldpLocChassisID        = "1.0.8802.1.1.2.1.3.2.0"
+	lldpLocChassisIDNexus   = "1.3.6.1.2.1.2.2.1.6"
+	lldpRemChassisID        = "1.0.8802.1.1.2.1.4.1.1.5"
+	lldpRemPortID           = "1.0.8802.1.1.2.1.4.1.1.7"
+	lldpLocChassisIDSubtype = "1.0.8802.1.1.2.1.3.1.0"
+	lldpRemChassisIDSubtype = "1.0.8802.1.1.2.1.4.1.1.4"
+	lldpRemPortIDSubtype    = "1.0.8802.1.1.2.1.4.1.1.6"
+	lldpLocPortID           = "1.0.8802.1.1.2.1.3.7.1.3"
+	lldpRemSysName          = "1.0.8802.1.1.2.1.4.1.1.9"
+	lldpRemManAddrEntry     = "1.0.8802.1.1.2.1.4.2.1"
+	sysObjectID             = "1.3.6.1.2.1.1.2.0"
+	ifName                  = "1.3.6.1.2.1.31.1.1.1.1"
+	cdpCacheAddress         = "1.3.6.1.4.1.9.9.23.1.2.1.1.4"
+	cdpCacheDeviceId        = "1.3.6.1.4.1.9.9.23.1.2.1.1.6"
+	cdpCacheDevicePort      = "1.3.6.1.4.1.9.9.23.1.2.1.1.7"
+	cdpCachePlatform        = "1.3.6.1.4.1.9.9.23.1.2.1.1.8"
 )
 
+/*
+* NetNodeHandler使用的SNMP操作，实时扫描时为gosnmp，离线回放时为采集文件
+ */
+type SNMPClient interface {
+	Connect() error
+	Close() error
+	Get(oids []string) (*gosnmp.SnmpPacket, error)
+	BulkWalkAll(rootOid string) ([]gosnmp.SnmpPDU, error)
+}
+
+type liveClient struct {
+	*gosnmp.GoSNMP
+}
+
+func (c *liveClient) Close() error {
+	return c.Conn.Close()
+}
+
 type NetNodeHandler struct {
-	node  *NetNode
-	snmpd *gosnmp.GoSNMP
+	node    *NetNode
+	snmpd   SNMPClient
+	profile *VendorProfile
+}
+
+var snmpAuthProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+	"MD5":     gosnmp.MD5,
+	"SHA":     gosnmp.SHA,
+	"SHA256":  gosnmp.SHA256,
+	"SHA-256": gosnmp.SHA256,
+}
+
+var snmpPrivProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+	"DES":     gosnmp.DES,
+	"AES":     gosnmp.AES,
+	"AES256":  gosnmp.AES256,
+	"AES-256": gosnmp.AES256,
 }
 
-func NewNetNodeHandler(netnode *NetNode, community string) *NetNodeHandler {
+func NewNetNodeHandler(netnode *NetNode, credential *SNMPCredential) (*NetNodeHandler, error) {
+	snmpd := &gosnmp.GoSNMP{
+		Target:         netnode.Mgt,
+		Port:           uint16(161),
+		Retries:        1,
+		Timeout:        time.Duration(3) * time.Second,
+		MaxRepetitions: 3}
+
+	switch credential.Version {
+	case "", "2c":
+		snmpd.Version = gosnmp.Version2c
+		snmpd.Community = credential.Community
+	case "3":
+		usm, flags, err := usmSecurityParameters(credential)
+		if err != nil {
+			return nil, err
+		}
+		snmpd.Version = gosnmp.Version3
+		snmpd.SecurityModel = gosnmp.UserSecurityModel
+		snmpd.MsgFlags = flags
+		snmpd.SecurityParameters = usm
+		snmpd.ContextName = credential.ContextName
+	default:
+		return nil, fmt.Errorf("Unsupport snmp version '%s'", credential.Version)
+	}
+
 	return &NetNodeHandler{
-		node: netnode,
-		snmpd: &gosnmp.GoSNMP{
-			Target:         netnode.Mgt,
-			Port:           uint16(161),
-			Community:      community,
-			Version:        gosnmp.Version2c,
-			Retries:        1,
-			Timeout:        time.Duration(3) * time.Second,
-			MaxRepetitions: 3},
+		node:    netnode,
+		snmpd:   &liveClient{snmpd},
+		profile: defaultVendorProfile,
+	}, nil
+}
+
+func usmSecurityParameters(credential *SNMPCredential) (*gosnmp.UsmSecurityParameters, gosnmp.SnmpV3MsgFlags, error) {
+	usm := &gosnmp.UsmSecurityParameters{
+		UserName:               credential.User,
+		AuthenticationProtocol: gosnmp.NoAuth,
+		PrivacyProtocol:        gosnmp.NoPriv,
 	}
+	if credential.AuthProtocol == "" {
+		return usm, gosnmp.NoAuthNoPriv, nil
+	}
+
+	auth, ok := snmpAuthProtocols[strings.ToUpper(credential.AuthProtocol)]
+	if !ok {
+		return nil, 0, fmt.Errorf("Unsupport snmp auth protocol '%s'", credential.AuthProtocol)
+	}
+	usm.AuthenticationProtocol = auth
+	usm.AuthenticationPassphrase = credential.AuthPassword
+	if credential.PrivProtocol == "" {
+		return usm, gosnmp.AuthNoPriv, nil
+	}
+
+	priv, ok := snmpPrivProtocols[strings.ToUpper(credential.PrivProtocol)]
+	if !ok {
+		return nil, 0, fmt.Errorf("Unsupport snmp priv protocol '%s'", credential.PrivProtocol)
+	}
+	usm.PrivacyProtocol = priv
+	usm.PrivacyPassphrase = credential.PrivPassword
+	return usm, gosnmp.AuthPriv, nil
 }
 
 func (n *NetNodeHandler) SNMPConnect() error {
 	return n.snmpd.Connect()
 }
 
+/*
+* 通过读取sysObjectID确认设备接受当前的认证信息，并根据sysObjectID选择厂商模板
+ */
+func (n *NetNodeHandler) Probe() error {
+	resp, err := n.snmpd.Get([]string{sysObjectID})
+	if err != nil {
+		return err
+	}
+
+	sysoid := ""
+	if len(resp.Variables) > 0 && resp.Variables[0].Type == gosnmp.ObjectIdentifier {
+		sysoid, _ = resp.Variables[0].Value.(string)
+	}
+	n.profile = LookupVendorProfile(sysoid, n.node)
+	return nil
+}
+
 func (n *NetNodeHandler) SNMPClose() error {
-	return n.snmpd.Conn.Close()
+	return n.snmpd.Close()
 }
 
 func (n *NetNodeHandler) SelfChassisID() ([]string, error) {
 	var result = []string{}
-	if strings.Contains(n.node.Model, "Nexus") {
-		resp, err := n.snmpd.BulkWalkAll(lldpLocChassisIDNexus)
+	if n.profile.LocalChassisWalk {
+		resp, err := n.snmpd.BulkWalkAll(n.profile.LocalChassisOID)
 		if err != nil {
 			return nil, err
 		}
+		// 环回、隧道等接口没有MAC地址，返回空值或全0
 		for _, pdu := range resp {
 			switch pdu.Type {
 			case gosnmp.OctetString:
-				result = append(result, hex.EncodeToString(pdu.Value.([]byte)))
+				if mac := pdu.Value.([]byte); !zeroBytes(mac) {
+					result = append(result, hex.EncodeToString(mac))
+				}
 			}
 		}
 
 	} else {
-		_resp, err := n.snmpd.Get([]string{lldpLocChassisID})
+		_resp, err := n.snmpd.Get([]string{n.profile.LocalChassisOID, n.profile.LocalChassisSubtypeOID})
 		if err != nil {
 			return nil, err
 		}
 
 		resp := _resp.Variables[0]
+		subtype := 0
+		if len(_resp.Variables) > 1 {
+			subtype = pduInt(_resp.Variables[1])
+		}
 
 		switch resp.Type {
 		case gosnmp.OctetString:
-			result = append(result, hex.EncodeToString(resp.Value.([]byte)))
+			raw := resp.Value.([]byte)
+			if id := decodeChassisID(subtype, raw); id.Value != "" && !zeroBytes(raw) {
+				result = append(result, id.Value)
+			}
 		}
 	}
 
 	return result, nil
 }
-func (n *NetNodeHandler) RemChassisID() (map[string]string, error) {
-	result := make(map[string]string)
-	resp, err := n.snmpd.BulkWalkAll(lldpRemChassisID)
+
+func zeroBytes(value []byte) bool {
+	for _, b := range value {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+* LLDP中的chassis id和port id，Subtype为解码时使用的子类型名称
+ */
+type LLDPID struct {
+	Subtype string
+	Value   string
+}
+
+var lldpChassisSubtypes = map[int]string{
+	1: "chassisComponent",
+	2: "ifAlias",
+	3: "portComponent",
+	4: "mac",
+	5: "networkAddress",
+	6: "ifName",
+	7: "local",
+}
+
+var lldpPortSubtypes = map[int]string{
+	1: "ifAlias",
+	2: "portComponent",
+	3: "mac",
+	4: "networkAddress",
+	5: "ifName",
+	6: "agentCircuitId",
+	7: "local",
+}
+
+/*
+* 按子类型解码chassis id，未知子类型时按MAC地址的格式处理。
+* 本端和对端的chassis id必须使用相同的解码方式才能匹配
+ */
+func decodeChassisID(subtype int, value []byte) LLDPID {
+	name, ok := lldpChassisSubtypes[subtype]
+	if !ok {
+		name = "mac"
+	}
+	return LLDPID{name, decodeLLDPID(name, value)}
+}
+
+/*
+* 按子类型解码port id，未知子类型时按字符串处理
+ */
+func decodePortID(subtype int, value []byte) LLDPID {
+	name, ok := lldpPortSubtypes[subtype]
+	if !ok {
+		return LLDPID{"", strings.TrimSpace(string(value))}
+	}
+	return LLDPID{name, decodeLLDPID(name, value)}
+}
+
+func decodeLLDPID(subtype string, value []byte) string {
+	switch subtype {
+	case "mac":
+		if len(value) != 6 {
+			return ""
+		}
+		return hex.EncodeToString(value)
+	case "networkAddress":
+		// 第一个字节为IANA地址族，1为IPv4，2为IPv6
+		if len(value) == net.IPv4len+1 && value[0] == 1 || len(value) == net.IPv6len+1 && value[0] == 2 {
+			return net.IP(value[1:]).String()
+		}
+		return hex.EncodeToString(value)
+	case "agentCircuitId":
+		return hex.EncodeToString(value)
+	default:
+		return strings.TrimSpace(string(value))
+	}
+}
+
+func (n *NetNodeHandler) RemChassisID() (map[string]LLDPID, error) {
+	result := make(map[string]LLDPID)
+	resp, err := n.snmpd.BulkWalkAll(n.profile.RemChassisOID)
+	if err != nil {
+		return nil, err
+	}
+
+	subtypes, err := n.walkInteger(n.profile.RemChassisSubtypeOID)
 	if err != nil {
 		return nil, err
 	}
@@ -86,18 +294,23 @@ func (n *NetNodeHandler) RemChassisID() (map[string]string, error) {
 
 		switch pdu.Type {
 		case gosnmp.OctetString:
-			h := hex.EncodeToString(pdu.Value.([]byte))
-			if len(h) == 12 {
-				result[index] = h
+			id := decodeChassisID(subtypes[oidIndex(pdu.Name, n.profile.RemChassisOID)], pdu.Value.([]byte))
+			if id.Value != "" {
+				result[index] = id
 			}
 		}
 	}
 	return result, err
 }
 
-func (n *NetNodeHandler) RemPort() (map[string]string, error) {
-	result := make(map[string]string)
-	resp, err := n.snmpd.BulkWalkAll(lldpRemPortID)
+func (n *NetNodeHandler) RemPort() (map[string]LLDPID, error) {
+	result := make(map[string]LLDPID)
+	resp, err := n.snmpd.BulkWalkAll(n.profile.RemPortOID)
+	if err != nil {
+		return nil, err
+	}
+
+	subtypes, err := n.walkInteger(n.profile.RemPortSubtypeOID)
 	if err != nil {
 		return nil, err
 	}
@@ -108,7 +321,7 @@ func (n *NetNodeHandler) RemPort() (map[string]string, error) {
 
 		switch pdu.Type {
 		case gosnmp.OctetString:
-			result[index] = string(pdu.Value.([]byte))
+			result[index] = decodePortID(subtypes[oidIndex(pdu.Name, n.profile.RemPortOID)], pdu.Value.([]byte))
 
 		}
 	}
@@ -118,7 +331,7 @@ func (n *NetNodeHandler) RemPort() (map[string]string, error) {
 func (n *NetNodeHandler) LocalPort() (map[string]string, error) {
 	result := make(map[string]string)
 
-	resp, err := n.snmpd.BulkWalkAll(lldpLocPortID)
+	resp, err := n.snmpd.BulkWalkAll(n.profile.LocPortOID)
 	if err != nil {
 		return nil, err
 	}
@@ -135,3 +348,163 @@ func (n *NetNodeHandler) LocalPort() (map[string]string, error) {
 	}
 	return result, err
 }
+
+func (n *NetNodeHandler) RemSysName() (map[string]string, error) {
+	result := make(map[string]string)
+	resp, err := n.walkOctetString(n.profile.RemSysNameOID)
+	if err != nil {
+		return nil, err
+	}
+
+	for index, value := range resp {
+		parts := strings.Split(index, ".")
+		if len(parts) == 3 && len(value) > 0 {
+			result[parts[1]] = string(value)
+		}
+	}
+	return result, nil
+}
+
+/*
+* 读取lldpRemManAddrTable，返回本端端口号到对端管理地址的映射。
+* 管理地址是表格索引的一部分：lldpRemTimeMark.lldpRemLocalPortNum.lldpRemIndex.
+* lldpRemManAddrSubtype.len.addr，subtype为1时是IPv4，为2时是IPv6
+ */
+func (n *NetNodeHandler) RemManAddr() (map[string][]string, error) {
+	result := make(map[string][]string)
+	resp, err := n.snmpd.BulkWalkAll(n.profile.RemManAddrOID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, pdu := range resp {
+		// 列号.lldpRemTimeMark.lldpRemLocalPortNum.lldpRemIndex.subtype.len.addr
+		parts := strings.Split(oidIndex(pdu.Name, n.profile.RemManAddrOID), ".")
+		if len(parts) < 7 {
+			continue
+		}
+		port, subtype, addr := parts[2], parts[4], parts[6:]
+		if (subtype != "1" || len(addr) != net.IPv4len) && (subtype != "2" || len(addr) != net.IPv6len) {
+			continue
+		}
+
+		ip := make(net.IP, len(addr))
+		for i, part := range addr {
+			b, err := strconv.ParseUint(part, 10, 8)
+			if err != nil {
+				ip = nil
+				break
+			}
+			ip[i] = byte(b)
+		}
+		if ip == nil || seen[port+"/"+ip.String()] {
+			continue
+		}
+		seen[port+"/"+ip.String()] = true
+		result[port] = append(result[port], ip.String())
+	}
+	return result, nil
+}
+
+/*
+* 按列遍历表格，返回索引（列OID之后的部分）到值的映射，只保留OctetString类型
+ */
+func (n *NetNodeHandler) walkOctetString(oid string) (map[string][]byte, error) {
+	result := make(map[string][]byte)
+	resp, err := n.snmpd.BulkWalkAll(oid)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pdu := range resp {
+		switch pdu.Type {
+		case gosnmp.OctetString:
+			result[oidIndex(pdu.Name, oid)] = pdu.Value.([]byte)
+		}
+	}
+	return result, nil
+}
+
+/*
+* 按列遍历表格，返回索引到整数值的映射
+ */
+func (n *NetNodeHandler) walkInteger(oid string) (map[string]int, error) {
+	result := make(map[string]int)
+	resp, err := n.snmpd.BulkWalkAll(oid)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pdu := range resp {
+		switch pdu.Type {
+		case gosnmp.Integer, gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Counter64, gosnmp.Uinteger32:
+			result[oidIndex(pdu.Name, oid)] = pduInt(pdu)
+		}
+	}
+	return result, nil
+}
+
+func pduInt(pdu gosnmp.SnmpPDU) int {
+	switch pdu.Type {
+	case gosnmp.Integer, gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Counter64, gosnmp.Uinteger32:
+		return int(gosnmp.ToBigInt(pdu.Value).Int64())
+	}
+	return 0
+}
+
+func oidIndex(name, oid string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(name, "."), oid+".")
+}
+
+type CDPEntry struct {
+	IfIndex    string
+	DeviceID   string
+	DevicePort string
+	Address    string
+	Platform   string
+}
+
+/*
+* 读取CISCO-CDP-MIB cdpCacheTable，索引为cdpCacheIfIndex.cdpCacheDeviceIndex
+ */
+func (n *NetNodeHandler) CDPCache() (map[string]*CDPEntry, error) {
+	result := make(map[string]*CDPEntry)
+	devices, err := n.walkOctetString(cdpCacheDeviceId)
+	if err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return result, nil
+	}
+
+	ports, err := n.walkOctetString(cdpCacheDevicePort)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses, err := n.walkOctetString(cdpCacheAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	platforms, err := n.walkOctetString(cdpCachePlatform)
+	if err != nil {
+		return nil, err
+	}
+
+	for index, device := range devices {
+		entry := &CDPEntry{
+			IfIndex:    strings.Split(index, ".")[0],
+			DeviceID:   string(device),
+			DevicePort: string(ports[index]),
+			Address:    "",
+			Platform:   string(platforms[index]),
+		}
+		if addr := addresses[index]; len(addr) == net.IPv4len || len(addr) == net.IPv6len {
+			entry.Address = net.IP(addr).String()
+		}
+		result[index] = entry
+	}
+	return result, nil
+}
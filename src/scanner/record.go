@@ -0,0 +1,239 @@
+package scanner
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/gosnmp"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	. "util"
+)
+
+/*
+* 扫描时记录的设备信息，回放时代替根据采集文件生成的设备
+ */
+const recordNodesFile = "netnodes.json"
+
+/*
+* 记录实时扫描时设备返回的所有PDU，每台设备保存为<mgt>.snmprec，可以用于离线回放。
+* 连接断开时写入文件并释放内存，扫描结束后调用Stop停止记录
+ */
+type Recorder struct {
+	Dir      string
+	lock     sync.Mutex
+	stopped  bool
+	captures map[string]map[string]gosnmp.SnmpPDU // key is the management ip, then the oid
+	files    map[string]*sync.Mutex               // 本次运行已写入的采集文件
+}
+
+/*
+* 记录到dir，通过LiveDialer的Recorder开启
+ */
+func NewRecorder(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		Dir:      dir,
+		lock:     sync.Mutex{},
+		captures: map[string]map[string]gosnmp.SnmpPDU{},
+		files:    map[string]*sync.Mutex{},
+	}, nil
+}
+
+/*
+* 停止记录，之后的连接不再记录
+ */
+func (r *Recorder) Stop() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stopped = true
+}
+
+/*
+* 保存资产信息中的设备，回放时设备的角色、标签等与扫描时一致
+ */
+func (r *Recorder) SaveNodes(netnodes []*NetNode) error {
+	data, err := json.MarshalIndent(netnodes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(r.Dir, recordNodesFile), data, 0644)
+}
+
+func (r *Recorder) add(mgt string, pdus []gosnmp.SnmpPDU) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.stopped {
+		return
+	}
+	capture, ok := r.captures[mgt]
+	if !ok {
+		capture = map[string]gosnmp.SnmpPDU{}
+		r.captures[mgt] = capture
+	}
+	for _, pdu := range pdus {
+		switch pdu.Type {
+		case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView:
+			continue
+		}
+		capture[pdu.Name] = pdu
+	}
+}
+
+/*
+* 同一台设备可能连接多次，每次断开时与本次运行已写入的文件合并后重写
+ */
+func (r *Recorder) save(mgt string) error {
+	r.lock.Lock()
+	capture := r.captures[mgt]
+	delete(r.captures, mgt)
+	if len(capture) == 0 {
+		r.lock.Unlock()
+		return nil
+	}
+	file, written := r.files[mgt]
+	if !written {
+		file = &sync.Mutex{}
+		r.files[mgt] = file
+	}
+	r.lock.Unlock()
+
+	file.Lock()
+	defer file.Unlock()
+	path := filepath.Join(r.Dir, mgt+".snmprec")
+	if written {
+		if err := mergeSnmprec(path, capture); err != nil {
+			Logger.Printf("[%s] Record, %v\n", mgt, err)
+		}
+	}
+
+	names := make([]string, 0, len(capture))
+	for name := range capture {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return compareOID(names[i], names[j]) < 0
+	})
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		line, err := FormatSnmprec(capture[name])
+		if err != nil {
+			Logger.Printf("[%s] Record %s, %v\n", mgt, name, err)
+			continue
+		}
+		lines = append(lines, line)
+	}
+	data := []byte(strings.Join(lines, "\n") + "\n")
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+/*
+* 读取之前写入的PDU，capture中已有的OID以新的取值为准
+ */
+func mergeSnmprec(path string, capture map[string]gosnmp.SnmpPDU) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	pdus, errs := ParseSnmprec(f)
+	for _, pdu := range pdus {
+		if _, ok := capture[pdu.Name]; !ok {
+			capture[pdu.Name] = pdu
+		}
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+/*
+* 转换成snmprec格式的一行，不可打印的字符串以十六进制保存
+ */
+func FormatSnmprec(pdu gosnmp.SnmpPDU) (string, error) {
+	oid := strings.TrimPrefix(pdu.Name, ".")
+	tag := fmt.Sprintf("%d", pdu.Type)
+	value := ""
+	switch pdu.Type {
+	case gosnmp.OctetString, gosnmp.BitString, gosnmp.Opaque:
+		raw, _ := pdu.Value.([]byte)
+		if printable(raw) {
+			value = string(raw)
+		} else {
+			tag, value = tag+"x", hex.EncodeToString(raw)
+		}
+	case gosnmp.Integer, gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Counter64, gosnmp.Uinteger32:
+		value = gosnmp.ToBigInt(pdu.Value).String()
+	case gosnmp.ObjectIdentifier:
+		s, _ := pdu.Value.(string)
+		value = strings.TrimPrefix(s, ".")
+	case gosnmp.IPAddress:
+		value, _ = pdu.Value.(string)
+	case gosnmp.Null:
+	default:
+		return "", fmt.Errorf("unsupport type %d", pdu.Type)
+	}
+	return oid + "|" + tag + "|" + value, nil
+}
+
+func printable(raw []byte) bool {
+	for _, b := range raw {
+		if b < 0x20 || b > 0x7e || b == '|' {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+* 包装实时扫描的SNMP连接，记录每个请求返回的PDU
+ */
+type recordClient struct {
+	SNMPClient
+	recorder *Recorder
+	mgt      string
+}
+
+func (r *Recorder) wrap(mgt string, client SNMPClient) SNMPClient {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.stopped {
+		return client
+	}
+	return &recordClient{SNMPClient: client, recorder: r, mgt: mgt}
+}
+
+func (c *recordClient) Get(oids []string) (*gosnmp.SnmpPacket, error) {
+	packet, err := c.SNMPClient.Get(oids)
+	if err == nil && packet != nil {
+		c.recorder.add(c.mgt, packet.Variables)
+	}
+	return packet, err
+}
+
+func (c *recordClient) BulkWalkAll(rootOid string) ([]gosnmp.SnmpPDU, error) {
+	pdus, err := c.SNMPClient.BulkWalkAll(rootOid)
+	if err == nil {
+		c.recorder.add(c.mgt, pdus)
+	}
+	return pdus, err
+}
+
+func (c *recordClient) Close() error {
+	if err := c.recorder.save(c.mgt); err != nil {
+		Logger.Printf("[%s] Record, %v\n", c.mgt, err)
+	}
+	return c.SNMPClient.Close()
+}
@@ -0,0 +1,108 @@
+package scanner
+
+import (
+	"bytes"
+	"github.com/gosnmp"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatSnmprec(t *testing.T) {
+	pdus := []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.2.1.1.1.0", Type: gosnmp.OctetString, Value: []byte("Huawei VRP\r\nVersion 8.180")},
+		{Name: ".1.3.6.1.2.1.1.2.0", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.2011.2.239.12"},
+		{Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(4294967295)},
+		{Name: ".1.3.6.1.2.1.1.5.0", Type: gosnmp.OctetString, Value: []byte("core|1")},
+		{Name: ".1.3.6.1.2.1.1.6.0", Type: gosnmp.OctetString, Value: []byte{}},
+		{Name: ".1.3.6.1.2.1.2.2.1.6.1", Type: gosnmp.OctetString, Value: []byte{0x00, 0xe0, 0xfc, 0x12, 0x34, 0x56}},
+		{Name: ".1.3.6.1.2.1.2.2.1.10.1", Type: gosnmp.Counter32, Value: uint(4294967295)},
+		{Name: ".1.3.6.1.2.1.4.20.1.1.10.0.0.1", Type: gosnmp.IPAddress, Value: "10.0.0.1"},
+		{Name: ".1.3.6.1.2.1.4.20.1.2.10.0.0.1", Type: gosnmp.Integer, Value: -2},
+		{Name: ".1.3.6.1.2.1.31.1.1.1.6.1", Type: gosnmp.Counter64, Value: uint64(18446744073709551615)},
+		{Name: ".1.3.6.1.2.1.31.1.1.1.15.1", Type: gosnmp.Gauge32, Value: uint(100000)},
+		{Name: ".1.3.6.1.4.1.9.9.91.1.1.1.1.4.1", Type: gosnmp.Uinteger32, Value: uint32(7)},
+		{Name: ".1.3.6.1.4.1.9.9.91.1.1.1.1.5.1", Type: gosnmp.Null, Value: nil},
+	}
+
+	lines := []string{}
+	for _, pdu := range pdus {
+		line, err := FormatSnmprec(pdu)
+		if err != nil {
+			t.Fatalf("%s: %v", pdu.Name, err)
+		}
+		lines = append(lines, line)
+	}
+
+	got, errs := ParseSnmprec(strings.NewReader(strings.Join(lines, "\n")))
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if len(got) != len(pdus) {
+		t.Fatalf("got %d pdus, want %d", len(got), len(pdus))
+	}
+	for i := range pdus {
+		if !reflect.DeepEqual(got[i], pdus[i]) {
+			t.Errorf("%s: got %#v, want %#v (%s)", pdus[i].Name, got[i], pdus[i], lines[i])
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	discardLog()
+	recorder, err := NewRecorder(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay, err := LoadReplayClient(filepath.Join("testdata", "replay", "10.0.0.1.snmpwalk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(recorder.Dir, "10.0.0.1.snmprec")
+
+	// 同一台设备的两次连接，第二次写入时合并第一次的记录
+	client := recorder.wrap("10.0.0.1", replay)
+	if _, err := client.Get([]string{".1.3.6.1.2.1.1.5.0", ".1.3.6.1.2.1.1.4.0"}); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	client = recorder.wrap("10.0.0.1", replay)
+	if _, err := client.BulkWalkAll(ifDescr); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+
+	if len(recorder.captures) != 0 {
+		t.Errorf("captures not released: %v", recorder.captures)
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "1.3.6.1.2.1.1.5.0|4|sw1\n" +
+		"1.3.6.1.2.1.2.2.1.2.1|4|GigabitEthernet1/0/1\n" +
+		"1.3.6.1.2.1.2.2.1.2.2|4|GigabitEthernet1/0/2\n"
+	if string(data) != want {
+		t.Errorf("recorded:\n%s\nwant:\n%s", data, want)
+	}
+
+	// 停止后不再记录，也不改写已有的文件
+	recorder.Stop()
+	client = recorder.wrap("10.0.0.1", replay)
+	if client != SNMPClient(replay) {
+		t.Error("client is still recorded after stop")
+	}
+	if _, err := client.BulkWalkAll(ifType); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	if after, _ := ioutil.ReadFile(file); !bytes.Equal(after, data) {
+		t.Errorf("file rewritten after stop:\n%s", after)
+	}
+	if _, err := os.Stat(filepath.Join(recorder.Dir, "10.0.0.2.snmprec")); !os.IsNotExist(err) {
+		t.Errorf("unexpected capture file, %v", err)
+	}
+}
@@ -0,0 +1,491 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/gosnmp"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	. "util"
+)
+
+/*
+* 离线回放的采集文件，文件名为设备的管理地址：
+* <mgt>.snmprec为snmpsim格式，<mgt>.snmpwalk、<mgt>.walk、<mgt>.txt为snmpwalk -On的输出
+ */
+var replayExtensions = []string{".snmprec", ".snmpwalk", ".walk", ".txt"}
+
+/*
+* 离线回放的SNMPDialer，设备的SNMP请求都从dir中的采集文件读取
+ */
+type ReplaySource struct {
+	Dir   string
+	files map[string]string // key is the management ip
+}
+
+func NewReplaySource(dir string) (*ReplaySource, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &ReplaySource{Dir: dir, files: map[string]string{}}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		for _, ext := range replayExtensions {
+			mgt := strings.TrimSuffix(name, ext)
+			if mgt == name || net.ParseIP(mgt) == nil {
+				continue
+			}
+			if _, ok := r.files[mgt]; !ok {
+				r.files[mgt] = filepath.Join(dir, name)
+			}
+		}
+	}
+	if len(r.files) == 0 {
+		return nil, fmt.Errorf("No capture file found in %s", dir)
+	}
+	return r, nil
+}
+
+/*
+* 优先使用记录时保存的设备，否则根据采集文件生成设备，设备名称和厂商从采集到的系统信息中获取
+ */
+func (r *ReplaySource) Nodes() []*NetNode {
+	if data, err := ioutil.ReadFile(filepath.Join(r.Dir, recordNodesFile)); err == nil {
+		nodes := []*NetNode{}
+		err = json.Unmarshal(data, &nodes)
+		if err == nil {
+			return nodes
+		}
+		Logger.Printf("Replay %s, %v\n", recordNodesFile, err)
+	}
+
+	mgts := make([]string, 0, len(r.files))
+	for mgt := range r.files {
+		mgts = append(mgts, mgt)
+	}
+	sort.Strings(mgts)
+
+	nodes := make([]*NetNode, 0, len(mgts))
+	for _, mgt := range mgts {
+		node := &NetNode{Id: GenNodeID(mgt), Mgt: mgt, Lables: []string{"SWITCH"}}
+		nodehandler, err := r.Dial(node)
+		if err != nil {
+			Logger.Printf("[%s] Replay, %v\n", mgt, err)
+			continue
+		}
+		if err := nodehandler.System(); err != nil {
+			Logger.Printf("[%s] System, %v\n", mgt, err)
+		}
+		_ = nodehandler.SNMPClose()
+		node.Name = node.SysName
+		node.Vendor = node.SysVendor
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+func (r *ReplaySource) Dial(netnode *NetNode) (*NetNodeHandler, error) {
+	file, ok := r.files[netnode.Mgt]
+	if !ok {
+		return nil, fmt.Errorf("No capture file in %s", r.Dir)
+	}
+
+	client, err := LoadReplayClient(file)
+	if err != nil {
+		return nil, err
+	}
+	nodehandler := &NetNodeHandler{
+		node:    netnode,
+		snmpd:   client,
+		profile: defaultVendorProfile,
+	}
+	if err := nodehandler.Probe(); err != nil {
+		return nil, err
+	}
+	netnode.Credential = "replay"
+	return nodehandler, nil
+}
+
+/*
+* 按OID排序的采集数据，实现Get和BulkWalkAll
+ */
+type ReplayClient struct {
+	pdus  []gosnmp.SnmpPDU
+	index map[string]int
+}
+
+func LoadReplayClient(file string) (*ReplayClient, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var pdus []gosnmp.SnmpPDU
+	var errs []error
+	if strings.HasSuffix(file, ".snmprec") {
+		pdus, errs = ParseSnmprec(f)
+	} else {
+		pdus, errs = ParseSnmpwalk(f)
+	}
+	for _, err := range errs {
+		Logger.Printf("Replay %s, %v\n", filepath.Base(file), err)
+	}
+	if len(pdus) == 0 {
+		return nil, fmt.Errorf("%s, no valid variable", filepath.Base(file))
+	}
+
+	sort.SliceStable(pdus, func(i, j int) bool {
+		return compareOID(pdus[i].Name, pdus[j].Name) < 0
+	})
+	c := &ReplayClient{pdus: pdus, index: make(map[string]int, len(pdus))}
+	for i, pdu := range pdus {
+		c.index[pdu.Name] = i
+	}
+	return c, nil
+}
+
+func (c *ReplayClient) Connect() error {
+	return nil
+}
+
+func (c *ReplayClient) Close() error {
+	return nil
+}
+
+func (c *ReplayClient) Get(oids []string) (*gosnmp.SnmpPacket, error) {
+	packet := &gosnmp.SnmpPacket{Variables: make([]gosnmp.SnmpPDU, 0, len(oids))}
+	for _, oid := range oids {
+		name := "." + strings.TrimPrefix(oid, ".")
+		if i, ok := c.index[name]; ok {
+			packet.Variables = append(packet.Variables, c.pdus[i])
+		} else {
+			packet.Variables = append(packet.Variables, gosnmp.SnmpPDU{Name: name, Type: gosnmp.NoSuchObject})
+		}
+	}
+	return packet, nil
+}
+
+func (c *ReplayClient) BulkWalkAll(rootOid string) ([]gosnmp.SnmpPDU, error) {
+	root := "." + strings.TrimPrefix(rootOid, ".")
+	start := sort.Search(len(c.pdus), func(i int) bool {
+		return compareOID(c.pdus[i].Name, root) >= 0
+	})
+
+	result := []gosnmp.SnmpPDU{}
+	for _, pdu := range c.pdus[start:] {
+		if pdu.Name != root && !strings.HasPrefix(pdu.Name, root+".") {
+			break
+		}
+		switch pdu.Type {
+		case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView:
+			continue
+		}
+		result = append(result, pdu)
+	}
+	return result, nil
+}
+
+/*
+* 按数字逐段比较OID
+ */
+func compareOID(a, b string) int {
+	x := strings.Split(strings.TrimPrefix(a, "."), ".")
+	y := strings.Split(strings.TrimPrefix(b, "."), ".")
+	for i := 0; i < len(x) && i < len(y); i++ {
+		m, _ := strconv.ParseUint(x[i], 10, 64)
+		n, _ := strconv.ParseUint(y[i], 10, 64)
+		if m != n {
+			if m < n {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(x) - len(y)
+}
+
+/*
+* snmpsim的采集格式，每行为"oid|tag|value"，tag为ASN.1类型编号，以x结尾时value为十六进制。
+* 无法解析的行跳过，通过errs返回
+ */
+func ParseSnmprec(r io.Reader) (result []gosnmp.SnmpPDU, errs []error) {
+	result = []gosnmp.SnmpPDU{}
+	lines := bufio.NewScanner(r)
+	lines.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for lines.Scan() {
+		line++
+		text := strings.TrimRight(lines.Text(), "\r")
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.SplitN(text, "|", 3)
+		if len(fields) != 3 {
+			errs = append(errs, fmt.Errorf("line %d, invalid snmprec record", line))
+			continue
+		}
+
+		tag := fields[1]
+		if strings.Contains(tag, ":") {
+			// variation模块的参数不是设备的取值，无法回放
+			continue
+		}
+		encoded := strings.HasSuffix(tag, "x")
+		number, err := strconv.Atoi(strings.TrimSuffix(tag, "x"))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d, invalid tag '%s'", line, fields[1]))
+			continue
+		}
+
+		value := []byte(fields[2])
+		if encoded {
+			if value, err = hex.DecodeString(fields[2]); err != nil {
+				errs = append(errs, fmt.Errorf("line %d, %v", line, err))
+				continue
+			}
+		}
+
+		pdu, err := replayPDU(fields[0], gosnmp.Asn1BER(number), value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d, %v", line, err))
+			continue
+		}
+		result = append(result, pdu)
+	}
+	if err := lines.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return result, errs
+}
+
+/*
+* 把采集文件中的取值转换成gosnmp返回的类型
+ */
+func replayPDU(oid string, asn gosnmp.Asn1BER, value []byte) (gosnmp.SnmpPDU, error) {
+	pdu := gosnmp.SnmpPDU{Name: "." + strings.TrimPrefix(oid, "."), Type: asn}
+	text := string(value)
+	var err error
+	switch asn {
+	case gosnmp.Integer:
+		var v int64
+		v, err = strconv.ParseInt(text, 10, 64)
+		pdu.Value = int(v)
+	case gosnmp.OctetString, gosnmp.BitString, gosnmp.Opaque:
+		pdu.Value = value
+	case gosnmp.Null, gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView:
+		pdu.Value = nil
+	case gosnmp.ObjectIdentifier:
+		pdu.Value = "." + strings.TrimPrefix(text, ".")
+	case gosnmp.IPAddress:
+		if len(value) == net.IPv4len {
+			pdu.Value = net.IP(value).String()
+		} else {
+			pdu.Value = text
+		}
+	case gosnmp.Counter32, gosnmp.Gauge32:
+		var v uint64
+		v, err = strconv.ParseUint(text, 10, 32)
+		pdu.Value = uint(v)
+	case gosnmp.TimeTicks, gosnmp.Uinteger32:
+		var v uint64
+		v, err = strconv.ParseUint(text, 10, 32)
+		pdu.Value = uint32(v)
+	case gosnmp.Counter64:
+		var v uint64
+		v, err = strconv.ParseUint(text, 10, 64)
+		pdu.Value = v
+	default:
+		return pdu, fmt.Errorf("unsupport type %d", asn)
+	}
+	return pdu, err
+}
+
+var snmpwalkLine = regexp.MustCompile(`^(\.?[0-9]+(?:\.[0-9]+)*) = (.*)$`)
+
+var snmpwalkTypes = map[string]gosnmp.Asn1BER{
+	"STRING":          gosnmp.OctetString,
+	"Hex-STRING":      gosnmp.OctetString,
+	"BITS":            gosnmp.OctetString,
+	"Opaque":          gosnmp.Opaque,
+	"INTEGER":         gosnmp.Integer,
+	"OID":             gosnmp.ObjectIdentifier,
+	"IpAddress":       gosnmp.IPAddress,
+	"Network Address": gosnmp.IPAddress,
+	"Counter32":       gosnmp.Counter32,
+	"Gauge32":         gosnmp.Gauge32,
+	"Timeticks":       gosnmp.TimeTicks,
+	"Counter64":       gosnmp.Counter64,
+	"UInteger32":      gosnmp.Uinteger32,
+}
+
+/*
+* snmpwalk -On的输出，每个变量为".oid = TYPE: value"，字符串和十六进制取值可能跨多行。
+* 无法解析的变量跳过，通过errs返回
+ */
+func ParseSnmpwalk(r io.Reader) (result []gosnmp.SnmpPDU, errs []error) {
+	type record struct {
+		line  int
+		oid   string
+		value string
+	}
+	records := []*record{}
+
+	lines := bufio.NewScanner(r)
+	lines.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for lines.Scan() {
+		line++
+		text := strings.TrimRight(lines.Text(), "\r")
+		if m := snmpwalkLine.FindStringSubmatch(text); m != nil {
+			records = append(records, &record{line: line, oid: m[1], value: m[2]})
+		} else if len(records) > 0 {
+			last := records[len(records)-1]
+			last.value += "\n" + text
+		} else if strings.TrimSpace(text) != "" {
+			errs = append(errs, fmt.Errorf("line %d, invalid snmpwalk output", line))
+		}
+	}
+	if err := lines.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	result = make([]gosnmp.SnmpPDU, 0, len(records))
+	for _, r := range records {
+		pdu, ok, err := snmpwalkPDU(r.oid, r.value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d, %v", r.line, err))
+			continue
+		}
+		if ok {
+			result = append(result, pdu)
+		}
+	}
+	return result, errs
+}
+
+/*
+* DISPLAY-HINT为"1x:"的MAC地址列，snmpwalk不带-Ox时输出为"STRING: 0:1c:73:a:b:c"
+ */
+var snmpwalkPhysAddress = []string{ifPhysAddress, ipNetToMediaPhysAddress, ipNetToPhysicalPhysAddress}
+
+var snmpwalkHintMAC = regexp.MustCompile(`^[0-9a-fA-F]{1,2}([:-][0-9a-fA-F]{1,2})+$`)
+
+func physAddressColumn(oid string) bool {
+	oid = strings.TrimPrefix(oid, ".")
+	for _, column := range snmpwalkPhysAddress {
+		if strings.HasPrefix(oid, column+".") {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+* 把"0:1c:73:a:b:c"还原成字节
+ */
+func hintMAC(value string) []byte {
+	parts := strings.FieldsFunc(value, func(r rune) bool { return r == ':' || r == '-' })
+	result := make([]byte, 0, len(parts))
+	for _, part := range parts {
+		b, _ := strconv.ParseUint(part, 16, 8)
+		result = append(result, byte(b))
+	}
+	return result
+}
+
+func snmpwalkPDU(oid, text string) (gosnmp.SnmpPDU, bool, error) {
+	switch {
+	case text == `""`:
+		return gosnmp.SnmpPDU{Name: "." + strings.TrimPrefix(oid, "."), Type: gosnmp.OctetString, Value: []byte{}}, true, nil
+	case text == "NULL":
+		return gosnmp.SnmpPDU{Name: "." + strings.TrimPrefix(oid, "."), Type: gosnmp.Null}, true, nil
+	case strings.HasPrefix(text, "No Such"), strings.HasPrefix(text, "No more variables"):
+		return gosnmp.SnmpPDU{}, false, nil
+	}
+
+	// 设备返回的类型与MIB定义不一致时的格式为"Wrong Type (should be X): TYPE: value"
+	if strings.HasPrefix(text, "Wrong Type") {
+		if i := strings.Index(text, "): "); i >= 0 {
+			text = text[i+3:]
+		}
+	}
+
+	i := strings.Index(text, ": ")
+	if i < 0 {
+		// 空的Hex-STRING没有取值
+		i = strings.LastIndex(text, ":")
+		if i < 0 {
+			return gosnmp.SnmpPDU{}, false, fmt.Errorf("invalid value '%s'", text)
+		}
+	}
+	name, value := text[:i], strings.TrimPrefix(text[i+1:], " ")
+	asn, ok := snmpwalkTypes[name]
+	if !ok {
+		return gosnmp.SnmpPDU{}, false, fmt.Errorf("unsupport type '%s'", name)
+	}
+
+	var raw []byte
+	switch name {
+	case "STRING":
+		if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			value = strings.Replace(value[1:len(value)-1], `\"`, `"`, -1)
+		}
+		raw = []byte(value)
+		if physAddressColumn(oid) && snmpwalkHintMAC.MatchString(value) {
+			raw = hintMAC(value)
+		}
+	case "Hex-STRING", "BITS", "Opaque":
+		raw = snmpwalkHex(value)
+	case "INTEGER", "Timeticks":
+		// 枚举值为"up(1)"，Timeticks为"(12345) 0:02:03.45"
+		if l, r := strings.Index(value, "("), strings.Index(value, ")"); l >= 0 && r > l {
+			value = value[l+1 : r]
+		}
+		raw = []byte(strings.TrimSpace(value))
+	case "Network Address":
+		raw = snmpwalkHex(strings.Replace(value, ":", " ", -1))
+	case "IpAddress", "OID":
+		raw = []byte(strings.TrimSpace(value))
+	default:
+		// 可能带有单位，例如"Gauge32: 1000 Mbps"
+		if fields := strings.Fields(value); len(fields) > 0 {
+			raw = []byte(fields[0])
+		}
+	}
+
+	pdu, err := replayPDU(oid, asn, raw)
+	return pdu, err == nil, err
+}
+
+/*
+* 解析以空格或换行分隔的十六进制字节，遇到非十六进制字段(例如BITS的位名称)时结束
+ */
+func snmpwalkHex(value string) []byte {
+	result := []byte{}
+	for _, field := range strings.Fields(value) {
+		if len(field) != 2 {
+			break
+		}
+		b, err := hex.DecodeString(field)
+		if err != nil {
+			break
+		}
+		result = append(result, b...)
+	}
+	return result
+}
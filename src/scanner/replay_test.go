@@ -0,0 +1,161 @@
+package scanner
+
+import (
+	"bytes"
+	"github.com/gosnmp"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	. "util"
+)
+
+func discardLog() {
+	if Logger == nil {
+		Logger = log.New(ioutil.Discard, "", 0)
+	}
+}
+
+func loadCapture(t *testing.T, name string) map[string]gosnmp.SnmpPDU {
+	f, err := os.Open(filepath.Join("testdata", "replay", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var pdus []gosnmp.SnmpPDU
+	var errs []error
+	if filepath.Ext(name) == ".snmprec" {
+		pdus, errs = ParseSnmprec(f)
+	} else {
+		pdus, errs = ParseSnmpwalk(f)
+	}
+	// 两个采集文件中各有无法解析的变量
+	if len(errs) == 0 {
+		t.Errorf("%s: expected errors for the invalid variables", name)
+	}
+	for _, err := range errs {
+		t.Logf("%s: %v", name, err)
+	}
+
+	result := map[string]gosnmp.SnmpPDU{}
+	for _, pdu := range pdus {
+		result[pdu.Name] = pdu
+	}
+	return result
+}
+
+func checkPDU(t *testing.T, pdus map[string]gosnmp.SnmpPDU, oid string, asn gosnmp.Asn1BER, value interface{}) {
+	pdu, ok := pdus[oid]
+	if !ok {
+		t.Errorf("%s: missing", oid)
+		return
+	}
+	if pdu.Type != asn {
+		t.Errorf("%s: type = %v, want %v", oid, pdu.Type, asn)
+	}
+	if want, ok := value.([]byte); ok {
+		if got, _ := pdu.Value.([]byte); !bytes.Equal(got, want) {
+			t.Errorf("%s: value = %x, want %x", oid, got, want)
+		}
+	} else if pdu.Value != value {
+		t.Errorf("%s: value = %#v, want %#v", oid, pdu.Value, value)
+	}
+}
+
+func TestParseSnmpwalk(t *testing.T) {
+	pdus := loadCapture(t, "10.0.0.1.snmpwalk")
+
+	checkPDU(t, pdus, ".1.3.6.1.2.1.1.1.0", gosnmp.OctetString,
+		[]byte("Cisco IOS Software, C3750 Software (C3750-IPSERVICESK9-M), Version 12.2(55)SE\nTechnical Support: http://www.cisco.com/techsupport"))
+	checkPDU(t, pdus, ".1.3.6.1.2.1.1.2.0", gosnmp.ObjectIdentifier, ".1.3.6.1.4.1.9.1.516")
+	checkPDU(t, pdus, ".1.3.6.1.2.1.1.3.0", gosnmp.TimeTicks, uint32(12345))
+	checkPDU(t, pdus, ".1.3.6.1.2.1.2.2.1.3.1", gosnmp.Integer, 6)
+	checkPDU(t, pdus, ".1.3.6.1.2.1.2.2.1.6.1", gosnmp.OctetString, []byte{0x00, 0x1c, 0x73, 0x0a, 0x0b, 0x0c})
+	checkPDU(t, pdus, ".1.3.6.1.2.1.2.2.1.6.2", gosnmp.OctetString, []byte{0x00, 0x1c, 0x73, 0x0a, 0x0b, 0x0d})
+	checkPDU(t, pdus, ".1.3.6.1.2.1.4.20.1.1.10.0.0.1", gosnmp.IPAddress, "10.0.0.1")
+	checkPDU(t, pdus, ".1.3.6.1.2.1.4.22.1.2.1.10.0.0.2", gosnmp.OctetString, []byte{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0x00})
+	checkPDU(t, pdus, ".1.3.6.1.2.1.31.1.1.1.6.1", gosnmp.Counter64, uint64(123456789012))
+	checkPDU(t, pdus, ".1.3.6.1.2.1.31.1.1.1.15.1", gosnmp.Gauge32, uint(1000))
+	checkPDU(t, pdus, ".1.3.6.1.2.1.31.1.1.1.15.2", gosnmp.Integer, 1000)
+	checkPDU(t, pdus, ".1.3.6.1.2.1.31.1.1.1.18.1", gosnmp.OctetString, []byte("uplink to sw2"))
+	checkPDU(t, pdus, ".1.3.6.1.2.1.31.1.1.1.18.2", gosnmp.OctetString, []byte{})
+	checkPDU(t, pdus, ".1.0.8802.1.1.2.1.4.1.1.5.0.1.1", gosnmp.OctetString, []byte{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0x00})
+
+	// 非MAC地址列的字符串不按DISPLAY-HINT解析
+	checkPDU(t, pdus, ".1.0.8802.1.1.2.1.3.7.1.3.1", gosnmp.OctetString, []byte("Gi1/0/1"))
+	for _, oid := range []string{".1.3.6.1.2.1.99.1.1.1.4.1", ".1.3.6.1.4.1.9.9.23.1.2.1.1.6.1.1"} {
+		if _, ok := pdus[oid]; ok {
+			t.Errorf("%s: should be skipped", oid)
+		}
+	}
+}
+
+func TestParseSnmprec(t *testing.T) {
+	pdus := loadCapture(t, "10.0.0.2.snmprec")
+
+	checkPDU(t, pdus, ".1.3.6.1.2.1.1.1.0", gosnmp.OctetString, []byte("Cisco IOS Software\nC3560"))
+	checkPDU(t, pdus, ".1.3.6.1.2.1.1.2.0", gosnmp.ObjectIdentifier, ".1.3.6.1.4.1.9.1.516")
+	checkPDU(t, pdus, ".1.3.6.1.2.1.1.3.0", gosnmp.TimeTicks, uint32(100))
+	checkPDU(t, pdus, ".1.3.6.1.2.1.1.5.0", gosnmp.OctetString, []byte("sw2"))
+	checkPDU(t, pdus, ".1.3.6.1.2.1.2.2.1.6.1", gosnmp.OctetString, []byte{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0x01})
+	checkPDU(t, pdus, ".1.3.6.1.2.1.2.2.1.10.1", gosnmp.Counter32, uint(4294967295))
+	checkPDU(t, pdus, ".1.3.6.1.2.1.4.20.1.1.10.0.0.2", gosnmp.IPAddress, "10.0.0.2")
+	checkPDU(t, pdus, ".1.3.6.1.2.1.31.1.1.1.6.1", gosnmp.Counter64, uint64(18446744073709551615))
+	checkPDU(t, pdus, ".1.3.6.1.2.1.31.1.1.1.15.1", gosnmp.Gauge32, uint(1000))
+
+	for _, oid := range []string{".1.3.6.1.2.1.99.1.1.1.4.1", ".1.3.6.1.2.1.99.1.1.1.4.2", ".1.3.6.1.2.1.1.4.0"} {
+		if _, ok := pdus[oid]; ok {
+			t.Errorf("%s: should be skipped", oid)
+		}
+	}
+}
+
+func TestReplayCollector(t *testing.T) {
+	discardLog()
+	source, err := NewReplaySource(filepath.Join("testdata", "replay"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := source.Nodes()
+	if len(nodes) != 2 || nodes[0].Name != "sw1" || nodes[1].Name != "sw2" {
+		t.Fatalf("nodes = %+v", nodes)
+	}
+
+	collector := &SNMPCollector{Dialer: source}
+	ids := []string{}
+	neighbors, err := collector.Collect(nodes[0], func(id string) { ids = append(ids, id) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nodes[0].Credential != "replay" {
+		t.Errorf("credential = %q", nodes[0].Credential)
+	}
+	if len(ids) == 0 {
+		t.Error("no id registered")
+	}
+	if len(neighbors) != 1 {
+		t.Fatalf("got %d neighbors", len(neighbors))
+	}
+	neighbor := neighbors[0]
+	if neighbor.RemoteName != "sw2" || neighbor.LocalPort[0] != "Gi1/0/1" || neighbor.RemotePort[0] != "Gi0/1" {
+		t.Errorf("neighbor = %+v", *neighbor)
+	}
+
+	// 邻居由其他采集方式得到时，SNMP补充接口等信息
+	node := &NetNode{Mgt: "10.0.0.1"}
+	other := NewNetNeighbor(node)
+	other.AddPort("lldp", "Gi1/0/1", "Ethernet1", "interfaceName")
+	if err := collector.Enrich(node, []*NetNeighbor{other}, func(string) {}); err != nil {
+		t.Fatal(err)
+	}
+	if len(node.Interfaces) != 2 || node.SysName != "sw1" || other.LocalInterface[0] == nil {
+		t.Errorf("enriched node %+v, local interface %v", node, other.LocalInterface[0])
+	}
+
+	if _, err := source.Dial(&NetNode{Mgt: "10.0.0.3"}); err == nil {
+		t.Error("expected an error for a device without capture")
+	}
+}
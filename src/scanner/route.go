@@ -0,0 +1,289 @@
+package scanner
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	. "util"
+)
+
+const (
+	inetCidrRouteIfIndex = "1.3.6.1.2.1.4.24.7.1.7"
+	inetCidrRouteType    = "1.3.6.1.2.1.4.24.7.1.8"
+	inetCidrRouteProto   = "1.3.6.1.2.1.4.24.7.1.9"
+	inetCidrRouteMetric1 = "1.3.6.1.2.1.4.24.7.1.12"
+)
+
+var inetCidrRouteTypeName = map[int]string{
+	1: "other",
+	2: "reject",
+	3: "local",
+	4: "remote",
+	5: "blackhole",
+}
+
+var ipRouteProtocolName = map[int]string{
+	1:  "other",
+	2:  "local",
+	3:  "static",
+	4:  "icmp",
+	5:  "egp",
+	6:  "ggp",
+	7:  "hello",
+	8:  "rip",
+	9:  "isis",
+	10: "esis",
+	11: "igrp",
+	12: "bbnspf",
+	13: "ospf",
+	14: "bgp",
+	15: "idpr",
+	16: "eigrp",
+	17: "dvmrp",
+}
+
+/*
+* 解析inetCidrRouteTable的索引：
+* 目的地址类型.长度.目的地址.前缀长度.策略OID长度.策略OID.下一跳类型.长度.下一跳
+ */
+func inetCidrRouteIndex(index string) (string, string, error) {
+	parts := strings.Split(index, ".")
+	pos := 0
+	next := func() (int, error) {
+		if pos >= len(parts) {
+			return 0, fmt.Errorf("invalid inetCidrRouteTable index %s", index)
+		}
+		v, err := strconv.Atoi(parts[pos])
+		pos++
+		return v, err
+	}
+	address := func() (net.IP, error) {
+		if _, err := next(); err != nil {
+			return nil, err
+		}
+		size, err := next()
+		if err != nil || pos+size > len(parts) {
+			return nil, fmt.Errorf("invalid inetCidrRouteTable index %s", index)
+		}
+		ip := oidBytes(parts[pos : pos+size])
+		pos += size
+		if size != net.IPv4len && size != net.IPv6len {
+			// 类型为unknown的地址，例如没有下一跳的直连路由
+			return nil, nil
+		}
+		return net.IP(ip), nil
+	}
+
+	dest, err := address()
+	if err != nil || dest == nil {
+		return "", "", fmt.Errorf("invalid inetCidrRouteTable index %s", index)
+	}
+	prefixlen, err := next()
+	if err != nil {
+		return "", "", err
+	}
+	policylen, err := next()
+	if err != nil {
+		return "", "", err
+	}
+	pos += policylen
+	nexthop, err := address()
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix := ""
+	if v4 := dest.To4(); v4 != nil {
+		p, err := IPv4(v4.String() + "/" + strconv.Itoa(prefixlen))
+		if err != nil {
+			return "", "", err
+		}
+		prefix = p.NetAddress().String() + "/" + strconv.Itoa(prefixlen)
+	} else {
+		p, err := IPv6(dest.String() + "/" + strconv.Itoa(prefixlen))
+		if err != nil {
+			return "", "", err
+		}
+		prefix = p.NetAddress().String() + "/" + strconv.Itoa(prefixlen)
+	}
+
+	if nexthop == nil || nexthop.IsUnspecified() {
+		return prefix, "", nil
+	}
+	return prefix, nexthop.String(), nil
+}
+
+/*
+* 读取inetCidrRouteTable路由表
+ */
+func (n *NetNodeHandler) Routes() ([]*NetRoute, error) {
+	result := []*NetRoute{}
+
+	ifindexes, err := n.walkInteger(inetCidrRouteIfIndex)
+	if err != nil {
+		return nil, err
+	}
+	types, err := n.walkInteger(inetCidrRouteType)
+	if err != nil {
+		return nil, err
+	}
+	protos, err := n.walkInteger(inetCidrRouteProto)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := n.walkInteger(inetCidrRouteMetric1)
+	if err != nil {
+		return nil, err
+	}
+
+	for index, ifindex := range ifindexes {
+		prefix, nexthop, err := inetCidrRouteIndex(index)
+		if err != nil {
+			continue
+		}
+		result = append(result, &NetRoute{
+			Prefix:  prefix,
+			NextHop: nexthop,
+			IfIndex: int64(ifindex),
+			Port:    interfaceName(n.node, int64(ifindex)),
+			Type:    inetCidrRouteTypeName[types[index]],
+			Proto:   ipRouteProtocolName[protos[index]],
+			Metric:  int64(metrics[index]),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Prefix != result[j].Prefix {
+			return result[i].Prefix < result[j].Prefix
+		}
+		return result[i].NextHop < result[j].NextHop
+	})
+	return result, nil
+}
+
+/*
+* 最长匹配查找路由，等价路由返回第一条
+ */
+func lookupRoute(routes []*NetRoute, dest net.IP) *NetRoute {
+	var result *NetRoute
+	longest := -1
+	for _, route := range routes {
+		_, ipnet, err := net.ParseCIDR(route.Prefix)
+		if err != nil || !ipnet.Contains(dest) {
+			continue
+		}
+		if ones, _ := ipnet.Mask.Size(); ones > longest {
+			result, longest = route, ones
+		}
+	}
+	return result
+}
+
+type RouteHop struct {
+	Mgt   string
+	Route *NetRoute
+}
+
+/*
+* 从source设备开始逐跳查找到dest的转发路径，dest可以是地址或网段。
+* 到达直连路由、下一跳不在已扫描设备中或者没有路由时结束，出现环路时返回错误
+ */
+func TracePath(netnodes []*NetNode, source, dest string) ([]*RouteHop, error) {
+	ip := net.ParseIP(dest)
+	if ip == nil {
+		addr, _, err := net.ParseCIDR(dest)
+		if err != nil {
+			return nil, err
+		}
+		ip = addr
+	}
+
+	nodes := make(map[string]*NetNode, len(netnodes))
+	for _, node := range netnodes {
+		nodes[node.Mgt] = node
+	}
+	addresses := AddressIndex(netnodes)
+
+	result := []*RouteHop{}
+	visited := map[string]bool{}
+	current := source
+	for {
+		if visited[current] {
+			return result, fmt.Errorf("forwarding loop at %s", current)
+		}
+		visited[current] = true
+
+		node, ok := nodes[current]
+		if !ok {
+			return result, fmt.Errorf("unknown device %s", current)
+		}
+		route := lookupRoute(node.Routes, ip)
+		result = append(result, &RouteHop{Mgt: current, Route: route})
+		if route == nil || route.NextHop == "" {
+			return result, nil
+		}
+		next, ok := addresses[route.NextHop]
+		if !ok {
+			return result, nil
+		}
+		current = next
+	}
+}
+
+/*
+* 按对端设备归并下一跳，adjacent表示两台设备之间存在LINK_TO链路
+ */
+type NextHopGroup struct {
+	Local    string
+	Remote   string
+	Prefixes []string
+	NextHops []string
+	Ports    []string
+	Adjacent bool
+}
+
+func NextHops(netnodes []*NetNode, links []*NetNeighbor) []*NextHopGroup {
+	addresses := AddressIndex(netnodes)
+	adjacent := make(map[[2]string]bool, len(links))
+	for _, link := range links {
+		adjacent[[2]string{link.LocalIP, link.RemoteIP}] = true
+		adjacent[[2]string{link.RemoteIP, link.LocalIP}] = true
+	}
+
+	result := []*NextHopGroup{}
+	for _, node := range netnodes {
+		groups := map[string]*NextHopGroup{}
+		unresolved := map[string]bool{}
+		for _, route := range node.Routes {
+			if route.NextHop == "" || route.Type == "reject" || route.Type == "blackhole" {
+				continue
+			}
+			remote, ok := addresses[route.NextHop]
+			if !ok {
+				if !unresolved[route.NextHop] {
+					Logger.Printf("[%s]Unresolved next hop %s\n", node.Mgt, route.NextHop)
+					unresolved[route.NextHop] = true
+				}
+				continue
+			}
+			if remote == node.Mgt {
+				continue
+			}
+			group, ok := groups[remote]
+			if !ok {
+				group = &NextHopGroup{
+					Local:    node.Mgt,
+					Remote:   remote,
+					Adjacent: adjacent[[2]string{node.Mgt, remote}],
+				}
+				groups[remote] = group
+				result = append(result, group)
+			}
+			group.Prefixes = append(group.Prefixes, route.Prefix)
+			group.NextHops = append(group.NextHops, route.NextHop)
+			group.Ports = append(group.Ports, route.Port)
+		}
+	}
+	return result
+}
@@ -0,0 +1,296 @@
+package scanner
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	. "util"
+)
+
+const (
+	entAliasMappingIdentifier = "1.3.6.1.2.1.47.1.3.2.1.2"
+	ifIndexOID                = "1.3.6.1.2.1.2.2.1.1"
+
+	// EntitySensorDataType
+	sensorAmperes = 5
+	sensorWatts   = 6
+	sensorCelsius = 8
+	sensorDBm     = 14
+
+	// EntitySensorDataScale，units(9)为10^0，每级相差10^3
+	sensorScaleUnits = 9
+)
+
+/*
+* 传感器表，索引为entPhysicalIndex
+ */
+type SensorTable struct {
+	Type      string
+	Scale     string
+	Precision string
+	Value     string
+}
+
+var entPhySensorTable = &SensorTable{
+	Type:      "1.3.6.1.2.1.99.1.1.1.1",
+	Scale:     "1.3.6.1.2.1.99.1.1.1.2",
+	Precision: "1.3.6.1.2.1.99.1.1.1.3",
+	Value:     "1.3.6.1.2.1.99.1.1.1.4",
+}
+
+// CISCO-ENTITY-SENSOR-MIB entSensorValueTable
+var ciscoEntSensorTable = &SensorTable{
+	Type:      "1.3.6.1.4.1.9.9.91.1.1.1.1.1",
+	Scale:     "1.3.6.1.4.1.9.9.91.1.1.1.1.2",
+	Precision: "1.3.6.1.4.1.9.9.91.1.1.1.1.3",
+	Value:     "1.3.6.1.4.1.9.9.91.1.1.1.1.4",
+}
+
+var (
+	sensorTxRegex   = regexp.MustCompile(`(?i)\btx\b|transmit`)
+	sensorRxRegex   = regexp.MustCompile(`(?i)\brx\b|receive`)
+	sensorBiasRegex = regexp.MustCompile(`(?i)bias|current`)
+)
+
+type sensor struct {
+	Type  int
+	Value float64
+}
+
+func (n *NetNodeHandler) sensors(table *SensorTable) (map[int64]*sensor, error) {
+	result := make(map[int64]*sensor)
+
+	types, err := n.walkInteger(table.Type)
+	if err != nil || len(types) == 0 {
+		return result, err
+	}
+	scales, err := n.walkInteger(table.Scale)
+	if err != nil {
+		return nil, err
+	}
+	precisions, err := n.walkInteger(table.Precision)
+	if err != nil {
+		return nil, err
+	}
+	values, err := n.walkInteger(table.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	for index, t := range types {
+		value, ok := values[index]
+		if !ok {
+			continue
+		}
+		i, err := strconv.ParseInt(index, 10, 64)
+		if err != nil {
+			continue
+		}
+		scale := scales[index]
+		if scale == 0 {
+			scale = sensorScaleUnits
+		}
+		exp := (scale-sensorScaleUnits)*3 - precisions[index]
+		result[i] = &sensor{Type: t, Value: float64(value) * math.Pow10(exp)}
+	}
+	return result, nil
+}
+
+/*
+* entAliasMappingTable中物理实体到ifIndex的映射
+ */
+func (n *NetNodeHandler) aliasMapping() (map[int64]int64, error) {
+	result := make(map[int64]int64)
+	resp, err := n.snmpd.BulkWalkAll(entAliasMappingIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	for _, pdu := range resp {
+		value, ok := pdu.Value.(string)
+		if !ok || !strings.HasPrefix(strings.TrimPrefix(value, "."), ifIndexOID+".") {
+			continue
+		}
+		parts := strings.Split(oidIndex(pdu.Name, entAliasMappingIdentifier), ".")
+		index, err1 := strconv.ParseInt(parts[0], 10, 64)
+		ifindex, err2 := strconv.ParseInt(oidIndex(value, ifIndexOID), 10, 64)
+		if err1 == nil && err2 == nil {
+			result[index] = ifindex
+		}
+	}
+	return result, nil
+}
+
+/*
+* 找到传感器所在的接口：沿entPhysicalContainedIn向上查找有ifIndex映射的实体，
+* 找不到时使用名称以接口名开头的实体
+ */
+func sensorInterface(index int64, entities map[int64]*entity, aliases map[int64]int64, interfaces map[int64]*NetInterface) *NetInterface {
+	for i, current := 0, index; i < 8 && current != 0; i++ {
+		if ifindex, ok := aliases[current]; ok {
+			return interfaces[ifindex]
+		}
+		e, ok := entities[current]
+		if !ok {
+			break
+		}
+		current = e.ContainedIn
+	}
+
+	var result *NetInterface
+	for current, i := index, 0; i < 8 && current != 0; i++ {
+		e, ok := entities[current]
+		if !ok {
+			break
+		}
+		for _, intf := range interfaces {
+			if intf.Name == "" || !strings.HasPrefix(e.Name, intf.Name) {
+				continue
+			}
+			// 名称后面不能紧跟数字，避免Eth1/1匹配到Eth1/10
+			rest := e.Name[len(intf.Name):]
+			if rest != "" && rest[0] >= '0' && rest[0] <= '9' {
+				continue
+			}
+			if result == nil || len(intf.Name) > len(result.Name) {
+				result = intf
+			}
+		}
+		if result != nil {
+			return result
+		}
+		current = e.ContainedIn
+	}
+	return nil
+}
+
+/*
+* 读取光模块的收发光功率、温度和偏置电流。
+* 多通道光模块的光功率取最小值，温度和偏置电流取最大值
+ */
+func (n *NetNodeHandler) Optics(entities map[int64]*entity, interfaces map[int64]*NetInterface) error {
+	tables := []*SensorTable{entPhySensorTable}
+	if n.profile.SensorTable != nil {
+		tables = []*SensorTable{n.profile.SensorTable, entPhySensorTable}
+	}
+
+	sensors := map[int64]*sensor{}
+	for _, table := range tables {
+		result, err := n.sensors(table)
+		if err != nil {
+			return err
+		}
+		if len(result) > 0 {
+			sensors = result
+			break
+		}
+	}
+	if len(sensors) == 0 {
+		return nil
+	}
+
+	aliases, err := n.aliasMapping()
+	if err != nil {
+		return err
+	}
+
+	for index, s := range sensors {
+		e, ok := entities[index]
+		if !ok {
+			continue
+		}
+		name := e.Name + " " + e.Descr
+
+		power, value := false, s.Value
+		switch s.Type {
+		case sensorDBm:
+			power = true
+		case sensorWatts:
+			if value <= 0 {
+				continue
+			}
+			power, value = true, 10*math.Log10(value*1000)
+		case sensorCelsius, sensorAmperes:
+		default:
+			continue
+		}
+		if s.Type == sensorAmperes && !sensorBiasRegex.MatchString(name) {
+			continue
+		}
+		if power && !sensorTxRegex.MatchString(name) && !sensorRxRegex.MatchString(name) {
+			continue
+		}
+
+		intf := sensorInterface(index, entities, aliases, interfaces)
+		if intf == nil {
+			continue
+		}
+		if intf.Optics == nil {
+			intf.Optics = &NetOptics{}
+		}
+		optics := intf.Optics
+
+		switch {
+		case power && sensorTxRegex.MatchString(name):
+			optics.TxPower = worse(optics.TxPower, value, math.Min)
+		case power:
+			optics.RxPower = worse(optics.RxPower, value, math.Min)
+		case s.Type == sensorCelsius:
+			optics.Temperature = worse(optics.Temperature, value, math.Max)
+		case s.Type == sensorAmperes:
+			optics.Bias = worse(optics.Bias, value*1000, math.Max)
+		}
+	}
+	return nil
+}
+
+func worse(current *float64, value float64, pick func(x, y float64) float64) *float64 {
+	if current != nil {
+		value = pick(*current, value)
+	}
+	return &value
+}
+
+/*
+* 只有读到Tx或Rx光功率时dom为true。每一项只写入读到取值的端口，
+* 例如ltxpower为读到的光功率，ltxpowerports为对应的本端端口，没有读到的端口不出现
+ */
+func opticsProps(prefix string, interfaces []*NetInterface) map[string]interface{} {
+	dom := make([]bool, len(interfaces))
+	fields := []struct {
+		name  string
+		value func(optics *NetOptics) *float64
+	}{
+		{"txpower", func(optics *NetOptics) *float64 { return optics.TxPower }},
+		{"rxpower", func(optics *NetOptics) *float64 { return optics.RxPower }},
+		{"temp", func(optics *NetOptics) *float64 { return optics.Temperature }},
+		{"bias", func(optics *NetOptics) *float64 { return optics.Bias }},
+	}
+
+	result := map[string]interface{}{}
+	for _, field := range fields {
+		values := []float64{}
+		ports := []string{}
+		for _, intf := range interfaces {
+			if intf == nil || intf.Optics == nil {
+				continue
+			}
+			if v := field.value(intf.Optics); v != nil {
+				values = append(values, *v)
+				ports = append(ports, intf.Name)
+			}
+		}
+		if len(values) > 0 {
+			result[prefix+field.name] = values
+			result[prefix+field.name+"ports"] = ports
+		}
+	}
+
+	for i, intf := range interfaces {
+		if intf != nil && intf.Optics != nil {
+			dom[i] = intf.Optics.TxPower != nil || intf.Optics.RxPower != nil
+		}
+	}
+	result[prefix+"dom"] = dom
+	return result
+}
@@ -0,0 +1,31 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+	. "util"
+)
+
+func TestOpticsProps(t *testing.T) {
+	zero, low, temp := 0.0, -12.5, 41.0
+	interfaces := []*NetInterface{
+		{Name: "Ethernet1", Optics: &NetOptics{TxPower: &zero, RxPower: &low, Temperature: &temp}},
+		{Name: "Ethernet2", Optics: &NetOptics{Temperature: &temp}},
+		{Name: "Ethernet3"},
+		nil,
+	}
+
+	got := opticsProps("l", interfaces)
+	want := map[string]interface{}{
+		"ltxpower":      []float64{0},
+		"ltxpowerports": []string{"Ethernet1"},
+		"lrxpower":      []float64{-12.5},
+		"lrxpowerports": []string{"Ethernet1"},
+		"ltemp":         []float64{41, 41},
+		"ltempports":    []string{"Ethernet1", "Ethernet2"},
+		"ldom":          []bool{true, false, false, false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
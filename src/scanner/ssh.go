@@ -0,0 +1,85 @@
+package scanner
+
+import (
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"time"
+	. "util"
+)
+
+type SSHClient struct {
+	client *ssh.Client
+}
+
+/*
+* 登录设备，每条命令使用单独的session执行。
+* 使用known_hosts校验设备的主机密钥，只有显式配置skipverify时才不校验
+ */
+func DialSSH(host string, credential *SSHCredential) (*SSHClient, error) {
+	auth := []ssh.AuthMethod{}
+	if credential.KeyFile != "" {
+		key, err := ioutil.ReadFile(credential.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if credential.Password != "" {
+		auth = append(auth, ssh.Password(credential.Password))
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("No ssh password or key configured")
+	}
+
+	var hostkey ssh.HostKeyCallback
+	switch {
+	case credential.KnownHosts != "":
+		callback, err := knownhosts.New(credential.KnownHosts)
+		if err != nil {
+			return nil, err
+		}
+		hostkey = callback
+	case credential.SkipVerify:
+		hostkey = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, fmt.Errorf("No ssh knownhosts configured")
+	}
+
+	port := credential.Port
+	if port == 0 {
+		port = 22
+	}
+	client, err := ssh.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)), &ssh.ClientConfig{
+		User:            credential.User,
+		Auth:            auth,
+		HostKeyCallback: hostkey,
+		Timeout:         10 * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &SSHClient{client: client}, nil
+}
+
+func (c *SSHClient) Run(command string) (string, error) {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	output, err := session.CombinedOutput(command)
+	return string(output), err
+}
+
+func (c *SSHClient) Close() error {
+	return c.client.Close()
+}
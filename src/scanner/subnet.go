@@ -0,0 +1,68 @@
+package scanner
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	. "util"
+)
+
+type SubnetMember struct {
+	Mgt  string
+	Port string
+	IP   string
+}
+
+/*
+* 由接口地址归并得到的网段，P2P表示/30、/31或/127的点到点网段
+ */
+type Subnet struct {
+	Prefix  string
+	Version int
+	P2P     bool
+	Members []*SubnetMember
+}
+
+func subnetPrefix(addr *NetAddress) (string, int, bool) {
+	cidr := addr.IP + "/" + strconv.Itoa(addr.PrefixLen)
+	if strings.Contains(addr.IP, ":") {
+		prefix, err := IPv6(cidr)
+		if err != nil || addr.PrefixLen >= 128 {
+			return "", 0, false
+		}
+		return prefix.NetAddress().String() + "/" + strconv.Itoa(addr.PrefixLen), 6, addr.PrefixLen == 127
+	}
+	prefix, err := IPv4(cidr)
+	if err != nil || addr.PrefixLen >= 32 {
+		return "", 0, false
+	}
+	return prefix.NetAddress().String() + "/" + strconv.Itoa(addr.PrefixLen), 4, addr.PrefixLen >= 30
+}
+
+/*
+* 按网段归并所有设备的接口地址，主机路由(/32、/128)不参与归并
+ */
+func Subnets(netnodes []*NetNode) []*Subnet {
+	subnets := map[string]*Subnet{}
+	for _, node := range netnodes {
+		for _, addr := range node.Addresses {
+			prefix, version, p2p := subnetPrefix(addr)
+			if prefix == "" {
+				continue
+			}
+			subnet, ok := subnets[prefix]
+			if !ok {
+				subnet = &Subnet{Prefix: prefix, Version: version, P2P: p2p}
+				subnets[prefix] = subnet
+			}
+			subnet.Members = append(subnet.Members, &SubnetMember{Mgt: node.Mgt, Port: addr.Port, IP: addr.IP})
+		}
+	}
+
+	result := make([]*Subnet, 0, len(subnets))
+	for _, subnet := range subnets {
+		result = append(result, subnet)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Prefix < result[j].Prefix })
+	return result
+}
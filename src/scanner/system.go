@@ -0,0 +1,115 @@
+package scanner
+
+import (
+	"fmt"
+	"github.com/gosnmp"
+	"strings"
+	. "util"
+)
+
+const (
+	sysDescr    = "1.3.6.1.2.1.1.1.0"
+	sysUpTime   = "1.3.6.1.2.1.1.3.0"
+	sysName     = "1.3.6.1.2.1.1.5.0"
+	sysLocation = "1.3.6.1.2.1.1.6.0"
+)
+
+/*
+* 读取SNMPv2-MIB system组，结果写入NetNode
+ */
+func (n *NetNodeHandler) System() error {
+	resp, err := n.snmpd.Get([]string{sysDescr, sysObjectID, sysUpTime, sysName, sysLocation})
+	if err != nil {
+		return err
+	}
+
+	for _, pdu := range resp.Variables {
+		switch strings.TrimPrefix(pdu.Name, ".") {
+		case sysDescr:
+			n.node.SysDescr = pduString(pdu)
+		case sysObjectID:
+			n.node.SysObjectID = strings.TrimPrefix(pduString(pdu), ".")
+		case sysUpTime:
+			n.node.SysUpTime = int64(pduInt(pdu))
+		case sysName:
+			n.node.SysName = pduString(pdu)
+		case sysLocation:
+			n.node.SysLocation = pduString(pdu)
+		}
+	}
+	n.node.Profile = n.profile.Name
+	n.node.SysVendor = LookupVendorProfile(n.node.SysObjectID, &NetNode{}).Vendor
+	return nil
+}
+
+func pduString(pdu gosnmp.SnmpPDU) string {
+	switch pdu.Type {
+	case gosnmp.OctetString:
+		return strings.TrimSpace(string(pdu.Value.([]byte)))
+	case gosnmp.ObjectIdentifier:
+		return pdu.Value.(string)
+	}
+	return ""
+}
+
+func pduBytes(pdu gosnmp.SnmpPDU) []byte {
+	if pdu.Type == gosnmp.OctetString {
+		return pdu.Value.([]byte)
+	}
+	return nil
+}
+
+func normalizeModel(model string) string {
+	return strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.ToLower(model))
+}
+
+/*
+* 比较资产信息与设备上读取到的厂商、型号和名称，返回不一致的项。
+* 两边都有取值且不一致时记录为"<项>: ..."，资产信息中缺少的项单独记录为"missing: <项> ..."
+ */
+func reconcile(node *NetNode) []string {
+	result := []string{}
+
+	if node.SysVendor != "" {
+		if node.Vendor == "" {
+			result = append(result, fmt.Sprintf("missing: vendor device=%q", node.SysVendor))
+		} else if !strings.EqualFold(node.Vendor, node.SysVendor) {
+			profile := LookupVendorProfile(node.SysObjectID, &NetNode{})
+			if profile.VendorRegex == nil || !profile.VendorRegex.MatchString(node.Vendor) {
+				result = append(result, fmt.Sprintf("vendor: inventory=%q device=%q", node.Vendor, node.SysVendor))
+			}
+		}
+	}
+
+	chassis := ""
+	for _, module := range node.Modules {
+		if module.Class == "chassis" && module.Model != "" {
+			chassis = module.Model
+			break
+		}
+	}
+
+	// 优先使用ENTITY-MIB中机框的型号，没有时检查sysDescr中是否包含型号
+	switch {
+	case chassis != "" && node.Model == "":
+		result = append(result, fmt.Sprintf("missing: model device=%q", chassis))
+	case chassis != "":
+		if normalizeModel(chassis) != normalizeModel(node.Model) {
+			result = append(result, fmt.Sprintf("model: inventory=%q device=%q", node.Model, chassis))
+		}
+	case node.SysDescr != "" && node.Model != "":
+		if !strings.Contains(normalizeModel(node.SysDescr), normalizeModel(node.Model)) {
+			result = append(result, fmt.Sprintf("model: inventory=%q not in sysDescr", node.Model))
+		}
+	}
+
+	if node.SysName != "" {
+		if node.Name == "" {
+			result = append(result, fmt.Sprintf("missing: name device=%q", node.SysName))
+		} else if deviceName(node.SysName) != deviceName(node.Name) {
+			result = append(result, fmt.Sprintf("name: inventory=%q device=%q", node.Name, node.SysName))
+		}
+	}
+
+	return result
+}
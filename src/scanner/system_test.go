@@ -0,0 +1,38 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+	. "util"
+)
+
+func TestReconcile(t *testing.T) {
+	chassis := []*NetModule{{Class: "chassis", Model: "CE6850-48S6Q-HI"}}
+	tests := []struct {
+		node *NetNode
+		want []string
+	}{
+		{&NetNode{Vendor: "Huawei", Model: "CE6850-48S6Q-HI", Name: "leaf1", SysVendor: "Huawei", SysName: "leaf1", Modules: chassis}, []string{}},
+		{&NetNode{Vendor: "H3C", Model: "S6800", Name: "leaf1", SysVendor: "Huawei", SysName: "leaf2", Modules: chassis}, []string{
+			`vendor: inventory="H3C" device="Huawei"`,
+			`model: inventory="S6800" device="CE6850-48S6Q-HI"`,
+			`name: inventory="leaf1" device="leaf2"`,
+		}},
+		// 爬取的设备没有资产信息
+		{&NetNode{Vendor: "Cisco", Name: "sw1", SysVendor: "Cisco", SysName: "sw1", SysDescr: "Cisco IOS Software, C3750"}, []string{}},
+		{&NetNode{SysVendor: "Cisco", SysName: "sw1", Modules: []*NetModule{{Class: "chassis", Model: "WS-C3750X-48"}}}, []string{
+			`missing: vendor device="Cisco"`,
+			`missing: model device="WS-C3750X-48"`,
+			`missing: name device="sw1"`,
+		}},
+		{&NetNode{Vendor: "Cisco", Model: "N9K-C93180YC", Name: "sw1", SysVendor: "Cisco", SysName: "sw1", SysDescr: "Cisco IOS Software, C3750"}, []string{
+			`model: inventory="N9K-C93180YC" not in sysDescr`,
+		}},
+	}
+
+	for i, test := range tests {
+		if got := reconcile(test.node); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%d: got %q, want %q", i, got, test.want)
+		}
+	}
+}
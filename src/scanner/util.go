@@ -3,7 +3,9 @@ package scanner
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
 	"sync"
 	. "util"
 )
@@ -67,6 +69,61 @@ func (m *SafeMap) Get(key string) (val string, ok bool) {
 	return val, ok
 }
 
+/*
+* 统一设备名称的格式，去掉Nexus等设备在名称后附带的序列号以及域名
+ */
+func deviceName(name string) string {
+	if i := strings.Index(name, "("); i > 0 {
+		name = name[:i]
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	if net.ParseIP(name) == nil {
+		if i := strings.Index(name, "."); i > 0 {
+			name = name[:i]
+		}
+	}
+	return name
+}
+
+/*
+* 生成地址到设备管理地址的映射，用于确定BGP等协议中对端地址所属的设备。
+* 包含管理地址、带外管理地址、接口地址、BGP会话的本端地址、OSPF router id以及IS-IS system id
+ */
+func AddressIndex(netnodes []*NetNode) map[string]string {
+	result := make(map[string]string, len(netnodes)*2)
+	for _, node := range netnodes {
+		for _, addr := range node.Addresses {
+			result[addr.IP] = node.Mgt
+		}
+		if SpecifiedAddress(node.OSPFRouterID) {
+			result[node.OSPFRouterID] = node.Mgt
+		}
+		if node.ISISSysID != "" {
+			result[node.ISISSysID] = node.Mgt
+		}
+		for _, peer := range node.BGPPeers {
+			if SpecifiedAddress(peer.LocalAddr) {
+				result[peer.LocalAddr] = node.Mgt
+			}
+		}
+	}
+	for _, node := range netnodes {
+		if node.Oobmgt != "" {
+			result[node.Oobmgt] = node.Mgt
+		}
+		result[node.Mgt] = node.Mgt
+	}
+	return result
+}
+
+/*
+* 空地址以及0.0.0.0、::不属于任何设备，例如未建立的BGP会话的本端地址和对端router id
+ */
+func SpecifiedAddress(addr string) bool {
+	ip := net.ParseIP(addr)
+	return ip != nil && !ip.IsUnspecified()
+}
+
 /*
 * 用于解析API数据的结构体
  */
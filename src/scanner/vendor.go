@@ -0,0 +1,193 @@
+package scanner
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	. "util"
+)
+
+/*
+* 厂商模板，定义设备使用的OID以及各厂商的特殊处理。
+* 通过sysObjectID前缀、资产信息中的厂商或型号匹配设备，OID为空时使用标准LLDP-MIB。
+ */
+type VendorProfile struct {
+	Name        string
+	Vendor      string         // 厂商名称
+	SysObjectID []string       // sysObjectID前缀
+	VendorRegex *regexp.Regexp // 匹配资产信息中的厂商
+	ModelRegex  *regexp.Regexp // 匹配资产信息中的型号
+
+	LocalChassisOID        string
+	LocalChassisSubtypeOID string
+	LocalChassisWalk       bool // LocalChassisOID为表格，每一行都作为本端的chassis id，按MAC处理
+
+	RemChassisOID        string
+	RemChassisSubtypeOID string
+	RemPortOID           string
+	RemPortSubtypeOID    string
+	RemSysNameOID        string
+	RemManAddrOID        string
+
+	LocPortOID        string
+	LocPortSubtypeOID string
+	LocPortDescOID    string
+	PortNumIsIfIndex  bool // lldpLocPortNum直接等于ifIndex
+
+	BGPPeerTable *BGPPeerTable // 厂商私有的BGP邻居表，为空时只使用BGP4-MIB
+	SensorTable  *SensorTable  // 厂商私有的传感器表，为空时只使用ENTITY-SENSOR-MIB
+	LagTable     *LagTable     // 厂商私有的链路聚合成员表，为空时只使用LAG-MIB和ifStackTable
+
+	CLITemplates     []*CLITemplate     // SNMP不可用时通过SSH执行的命令
+	NetconfTemplates []*NetconfTemplate // 按顺序尝试的NETCONF RPC
+}
+
+var vendorProfiles = struct {
+	sync.RWMutex
+	list []*VendorProfile
+}{}
+
+var defaultVendorProfile = RegisterVendorProfile(&VendorProfile{Name: "default"})
+
+func setDefault(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+/*
+* 注册厂商模板，未设置的OID使用标准LLDP-MIB
+ */
+func RegisterVendorProfile(profile *VendorProfile) *VendorProfile {
+	setDefault(&profile.LocalChassisOID, lldpLocChassisID)
+	setDefault(&profile.LocalChassisSubtypeOID, lldpLocChassisIDSubtype)
+	setDefault(&profile.RemChassisOID, lldpRemChassisID)
+	setDefault(&profile.RemChassisSubtypeOID, lldpRemChassisIDSubtype)
+	setDefault(&profile.RemPortOID, lldpRemPortID)
+	setDefault(&profile.RemPortSubtypeOID, lldpRemPortIDSubtype)
+	setDefault(&profile.RemSysNameOID, lldpRemSysName)
+	setDefault(&profile.RemManAddrOID, lldpRemManAddrEntry)
+	setDefault(&profile.LocPortOID, lldpLocPortID)
+	setDefault(&profile.LocPortSubtypeOID, lldpLocPortIDSubtype)
+	setDefault(&profile.LocPortDescOID, lldpLocPortDesc)
+	if profile.CLITemplates == nil {
+		profile.CLITemplates = []*CLITemplate{ciscoLLDPTemplate}
+	}
+	if profile.NetconfTemplates == nil {
+		profile.NetconfTemplates = []*NetconfTemplate{openconfigLLDPTemplate}
+	}
+
+	vendorProfiles.Lock()
+	vendorProfiles.list = append(vendorProfiles.list, profile)
+	vendorProfiles.Unlock()
+	return profile
+}
+
+/*
+* 计算模板与设备的匹配程度，型号优先，其次是最长的sysObjectID前缀，最后是厂商
+ */
+func (p *VendorProfile) score(sysoid string, node *NetNode) int {
+	score := 0
+	if p.ModelRegex != nil && node.Model != "" && p.ModelRegex.MatchString(node.Model) {
+		score += 10000
+	}
+
+	prefixlen := 0
+	for _, prefix := range p.SysObjectID {
+		if (sysoid == prefix || strings.HasPrefix(sysoid, prefix+".")) && len(prefix) > prefixlen {
+			prefixlen = len(prefix)
+		}
+	}
+	if prefixlen > 0 {
+		score += 1000 + prefixlen
+	}
+
+	if p.VendorRegex != nil && node.Vendor != "" && p.VendorRegex.MatchString(node.Vendor) {
+		score += 1
+	}
+	return score
+}
+
+func LookupVendorProfile(sysoid string, node *NetNode) *VendorProfile {
+	sysoid = strings.TrimPrefix(sysoid, ".")
+
+	vendorProfiles.RLock()
+	defer vendorProfiles.RUnlock()
+
+	best, bestscore := defaultVendorProfile, 0
+	for _, profile := range vendorProfiles.list {
+		if score := profile.score(sysoid, node); score > bestscore {
+			best, bestscore = profile, score
+		}
+	}
+	return best
+}
+
+func init() {
+	RegisterVendorProfile(&VendorProfile{
+		Name:         "cisco",
+		Vendor:       "Cisco",
+		SysObjectID:  []string{"1.3.6.1.4.1.9"},
+		VendorRegex:  regexp.MustCompile(`(?i)cisco`),
+		BGPPeerTable: ciscoBGPPeer2Table,
+		SensorTable:  ciscoEntSensorTable,
+		LagTable:     ciscoPagpTable,
+		CLITemplates: []*CLITemplate{ciscoLLDPTemplate, ciscoCDPTemplate},
+	})
+
+	// Nexus的lldpLocChassisId与邻居看到的不一致，使用所有接口的ifPhysAddress
+	RegisterVendorProfile(&VendorProfile{
+		Name:             "cisco-nexus",
+		Vendor:           "Cisco",
+		SysObjectID:      []string{"1.3.6.1.4.1.9.12.3.1.3"},
+		ModelRegex:       regexp.MustCompile(`Nexus`),
+		LocalChassisOID:  lldpLocChassisIDNexus,
+		LocalChassisWalk: true,
+		BGPPeerTable:     ciscoBGPPeer2Table,
+		SensorTable:      ciscoEntSensorTable,
+		LagTable:         ciscoPagpTable,
+		CLITemplates:     []*CLITemplate{ciscoLLDPTemplate, ciscoCDPTemplate},
+	})
+
+	RegisterVendorProfile(&VendorProfile{
+		Name:             "h3c",
+		Vendor:           "H3C",
+		SysObjectID:      []string{"1.3.6.1.4.1.25506", "1.3.6.1.4.1.2011.10"},
+		VendorRegex:      regexp.MustCompile(`(?i)h3c|新华三`),
+		PortNumIsIfIndex: true,
+		CLITemplates:     []*CLITemplate{h3cLLDPTemplate},
+	})
+
+	RegisterVendorProfile(&VendorProfile{
+		Name:             "huawei",
+		Vendor:           "Huawei",
+		SysObjectID:      []string{"1.3.6.1.4.1.2011"},
+		VendorRegex:      regexp.MustCompile(`(?i)huawei|华为`),
+		CLITemplates:     []*CLITemplate{huaweiLLDPTemplate},
+		NetconfTemplates: []*NetconfTemplate{huaweiNetconfLLDPTemplate, huaweiVRPLLDPTemplate, openconfigLLDPTemplate},
+	})
+
+	RegisterVendorProfile(&VendorProfile{
+		Name:             "juniper",
+		Vendor:           "Juniper",
+		SysObjectID:      []string{"1.3.6.1.4.1.2636"},
+		VendorRegex:      regexp.MustCompile(`(?i)juniper`),
+		PortNumIsIfIndex: true,
+		NetconfTemplates: []*NetconfTemplate{junosLLDPTemplate, openconfigLLDPTemplate},
+	})
+
+	RegisterVendorProfile(&VendorProfile{
+		Name:         "arista",
+		Vendor:       "Arista",
+		SysObjectID:  []string{"1.3.6.1.4.1.30065"},
+		VendorRegex:  regexp.MustCompile(`(?i)arista`),
+		CLITemplates: []*CLITemplate{aristaLLDPTemplate},
+	})
+
+	RegisterVendorProfile(&VendorProfile{
+		Name:        "ruijie",
+		Vendor:      "Ruijie",
+		SysObjectID: []string{"1.3.6.1.4.1.4881"},
+		VendorRegex: regexp.MustCompile(`(?i)ruijie|锐捷`),
+	})
+}
@@ -12,10 +12,41 @@ type Config struct {
 	NeoServer   string `json:"neoserver"`
 	NeoUser     string `json:"neouser"`
 	NeoPassword string `json:"neopassword"`
+
+	SNMP          SNMPCredential            `json:"snmp"`
+	SNMPOverrides map[string]SNMPCredential `json:"snmpoverrides"` // key is the management ip
+	Credentials   map[string]SNMPCredential `json:"credentials"`
+	Profiles      []CredentialProfile       `json:"profiles"`
+
+	SSH          SSHCredential            `json:"ssh"`
+	SSHOverrides map[string]SSHCredential `json:"sshoverrides"` // key is the management ip
+	GNMI         GNMICredential           `json:"gnmi"`
+	Netconf      NetconfCredential        `json:"netconf"`
+
+	RouteRoles   []string `json:"routeroles"`   // 采集路由表的设备角色或标签，为空时不采集
+	PollInterval int64    `json:"pollinterval"` // 链路计数器采集间隔(秒)，为0时扫描完成后退出
+
+	Crawl CrawlConfig `json:"crawl"`
 }
 
+/*
+* 无CMDB时从种子地址开始递归发现设备，Seeds为空时使用CMDB
+ */
+type CrawlConfig struct {
+	Seeds    []string `json:"seeds"`
+	Allow    []string `json:"allow"`
+	Deny     []string `json:"deny"`
+	MaxDepth int      `json:"maxdepth"`
+}
+
+// 没有配置snmp时使用的community，与之前所有设备共用的community一致
+const DefaultCommunity = "360buy"
+
 func NewConfig(file string) (*Config, error) {
-	c := &Config{"", 1000, "", "", "", ""}
+	c := &Config{
+		SaveBatch: 1000,
+		SNMP:      SNMPCredential{Version: "2c", Community: DefaultCommunity},
+	}
 
 	data, err := io.ReadFile(file)
 	if err != nil {
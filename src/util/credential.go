@@ -0,0 +1,274 @@
+package util
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+/*
+* SNMP认证信息，Version为"2c"时使用Community，为"3"时使用USM参数
+ */
+type SNMPCredential struct {
+	Version      string `json:"version"`
+	Community    string `json:"community"`
+	User         string `json:"user"`
+	AuthProtocol string `json:"authprotocol"` // MD5, SHA, SHA256
+	AuthPassword string `json:"authpassword"`
+	PrivProtocol string `json:"privprotocol"` // DES, AES, AES256
+	PrivPassword string `json:"privpassword"`
+	ContextName  string `json:"contextname"`
+}
+
+/*
+* SSH登录信息，SNMP不可用时通过命令行采集邻居
+ */
+type SSHCredential struct {
+	User       string `json:"user"`
+	Password   string `json:"password"`
+	KeyFile    string `json:"keyfile"`
+	Port       int    `json:"port"`
+	KnownHosts string `json:"knownhosts"`
+	SkipVerify bool   `json:"skipverify"` // 没有known_hosts时必须显式开启才不校验主机密钥
+}
+
+/*
+* gNMI登录信息，CIDR内的设备优先使用gNMI采集邻居，失败时使用SNMP
+ */
+type GNMICredential struct {
+	User       string   `json:"user"`
+	Password   string   `json:"password"`
+	Port       int      `json:"port"`
+	TLS        bool     `json:"tls"`
+	CA         string   `json:"ca"` // 校验设备证书的CA文件，为空时使用系统的CA
+	SkipVerify bool     `json:"skipverify"`
+	CIDR       []string `json:"cidr"`
+}
+
+/*
+* NETCONF登录信息，CIDR内的设备优先使用NETCONF采集，失败时使用SNMP。端口默认为830
+ */
+type NetconfCredential struct {
+	SSHCredential
+	CIDR []string `json:"cidr"`
+}
+
+/*
+* 认证信息模板，按机房、角色、厂商以及管理地址网段匹配设备。
+* 匹配条件为空时表示不限制，Credentials为按顺序尝试的认证信息名称。
+ */
+type CredentialProfile struct {
+	Name        string   `json:"name"`
+	Datacenter  []string `json:"datacenter"`
+	Role        []string `json:"role"`
+	Vendor      []string `json:"vendor"`
+	CIDR        []string `json:"cidr"`
+	Credentials []string `json:"credentials"`
+}
+
+type NamedCredential struct {
+	Name string // "<profile>/<credential>"
+	SNMPCredential
+}
+
+type credentialProfile struct {
+	CredentialProfile
+	networks []*net.IPNet
+}
+
+type CredentialStore struct {
+	credentials map[string]SNMPCredential
+	profiles    []*credentialProfile
+	overrides   map[string]SNMPCredential
+	fallback    *SNMPCredential
+
+	ssh          *SSHCredential
+	sshoverrides map[string]SSHCredential
+
+	gnmi         *GNMICredential
+	gnminetworks []*net.IPNet
+
+	netconf         *NetconfCredential
+	netconfnetworks []*net.IPNet
+}
+
+func NewCredentialStore(c *Config) (*CredentialStore, error) {
+	s := &CredentialStore{
+		credentials: c.Credentials,
+		profiles:    make([]*credentialProfile, 0, len(c.Profiles)),
+		overrides:   c.SNMPOverrides,
+		fallback:    nil,
+
+		ssh:          nil,
+		sshoverrides: c.SSHOverrides,
+	}
+
+	if c.SSH.User != "" {
+		s.ssh = &c.SSH
+	}
+
+	if len(c.GNMI.CIDR) > 0 {
+		networks, err := parseNetworks(c.GNMI.CIDR)
+		if err != nil {
+			return nil, fmt.Errorf("gNMI has invalid cidr. %v", err)
+		}
+		s.gnmi, s.gnminetworks = &c.GNMI, networks
+	}
+
+	if len(c.Netconf.CIDR) > 0 {
+		networks, err := parseNetworks(c.Netconf.CIDR)
+		if err != nil {
+			return nil, fmt.Errorf("NETCONF has invalid cidr. %v", err)
+		}
+		s.netconf, s.netconfnetworks = &c.Netconf, networks
+	}
+
+	if c.SNMP.Community != "" || c.SNMP.Version == "3" {
+		s.fallback = &c.SNMP
+	}
+
+	if s.fallback == nil && len(c.Profiles) == 0 && len(c.SNMPOverrides) == 0 {
+		return nil, fmt.Errorf("No snmp credential configured")
+	}
+
+	for _, profile := range c.Profiles {
+		p := &credentialProfile{profile, make([]*net.IPNet, 0, len(profile.CIDR))}
+		for _, cidr := range profile.CIDR {
+			_, ipnet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("Profile '%s' has invalid cidr. %v", profile.Name, err)
+			}
+			p.networks = append(p.networks, ipnet)
+		}
+		for _, name := range profile.Credentials {
+			if _, ok := s.credentials[name]; !ok {
+				return nil, fmt.Errorf("Profile '%s' refers to unknown credential '%s'", profile.Name, name)
+			}
+		}
+		s.profiles = append(s.profiles, p)
+	}
+	return s, nil
+}
+
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	result := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ipnet)
+	}
+	return result, nil
+}
+
+func containsIP(networks []*net.IPNet, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipnet := range networks {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *credentialProfile) match(node *NetNode) bool {
+	if !matchAny(p.Datacenter, node.Datacenter) ||
+		!matchAny(p.Role, node.Role) ||
+		!matchAny(p.Vendor, node.Vendor) {
+		return false
+	}
+	if len(p.networks) == 0 {
+		return true
+	}
+	ip := net.ParseIP(node.Mgt)
+	for _, ipnet := range p.networks {
+		if ip != nil && ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+* 返回设备需要按顺序尝试的认证信息：
+* 设备单独配置的认证信息，第一个匹配的模板中的认证信息，最后是默认认证信息
+ */
+func (s *CredentialStore) Select(node *NetNode) []*NamedCredential {
+	result := []*NamedCredential{}
+	if credential, ok := s.overrides[node.Mgt]; ok {
+		result = append(result, &NamedCredential{"override", credential})
+	}
+
+	for _, p := range s.profiles {
+		if !p.match(node) {
+			continue
+		}
+		for _, name := range p.Credentials {
+			result = append(result, &NamedCredential{p.Name + "/" + name, s.credentials[name]})
+		}
+		break
+	}
+
+	if s.fallback != nil {
+		result = append(result, &NamedCredential{"default", *s.fallback})
+	}
+	return result
+}
+
+/*
+* 根据Select返回的名称重新获取认证信息，用于复用扫描时已验证的认证信息
+ */
+func (s *CredentialStore) Get(node *NetNode, name string) (*NamedCredential, bool) {
+	for _, credential := range s.Select(node) {
+		if credential.Name == name {
+			return credential, true
+		}
+	}
+	return nil, false
+}
+
+/*
+* 返回设备的SSH登录信息，没有配置时返回nil
+ */
+func (s *CredentialStore) SSH(node *NetNode) *SSHCredential {
+	if credential, ok := s.sshoverrides[node.Mgt]; ok {
+		return &credential
+	}
+	return s.ssh
+}
+
+/*
+* 管理地址在gNMI网段内时返回gNMI登录信息，否则返回nil
+ */
+func (s *CredentialStore) GNMI(node *NetNode) *GNMICredential {
+	if s.gnmi == nil || !containsIP(s.gnminetworks, node.Mgt) {
+		return nil
+	}
+	return s.gnmi
+}
+
+/*
+* 管理地址在NETCONF网段内时返回NETCONF登录信息，否则返回nil
+ */
+func (s *CredentialStore) Netconf(node *NetNode) *NetconfCredential {
+	if s.netconf == nil || !containsIP(s.netconfnetworks, node.Mgt) {
+		return nil
+	}
+	return s.netconf
+}
@@ -0,0 +1,162 @@
+package util
+
+import "time"
+
+/*
+* 设备接口信息，来自IF-MIB
+ */
+type NetInterface struct {
+	Index       int64
+	Type        int // ifType
+	Name        string
+	Descr       string
+	Alias       string
+	Speed       int64 // Mbps
+	OperStatus  string
+	AdminStatus string
+	Vlans       []int      // 接口允许通过的VLAN
+	Pvid        int        // native VLAN
+	Optics      *NetOptics // 光模块DOM读数，没有光模块时为nil
+}
+
+/*
+* 按端口名称查找接口，名称可以是ifName、ifDescr或设备通过LLDP通告的port id
+ */
+func (node *NetNode) Interface(port string) *NetInterface {
+	if index, ok := node.Ports[port]; ok {
+		return node.Interfaces[index]
+	}
+	return nil
+}
+
+/*
+* 链路聚合接口及其成员端口，Bundled表示成员端口已加入聚合
+ */
+type NetLag struct {
+	Index   int64
+	Members []*NetLagMember
+}
+
+type NetLagMember struct {
+	Index   int64
+	Bundled bool
+}
+
+/*
+* 查找接口所属的链路聚合，index为聚合接口或成员端口的ifIndex
+ */
+func (node *NetNode) Lag(index int64) *NetLag {
+	if lag, ok := node.Lags[index]; ok {
+		return lag
+	}
+	for _, lag := range node.Lags {
+		for _, member := range lag.Members {
+			if member.Index == index {
+				return lag
+			}
+		}
+	}
+	return nil
+}
+
+/*
+* 设备硬件信息，来自ENTITY-MIB，Class为chassis、module、transceiver、powerSupply或fan
+ */
+type NetModule struct {
+	Index       int64
+	Class       string
+	Name        string
+	Descr       string
+	Model       string
+	Serial      string
+	HardwareRev string
+	FirmwareRev string
+	SoftwareRev string
+	Slot        string
+}
+
+/*
+* 交换机边缘端口上学习到的终端MAC地址
+ */
+type NetEndpoint struct {
+	Mac     string
+	Vlan    int   // 无法确定时为0
+	Vlans   []int // 共享VLAN学习时FDB ID对应的全部VLAN
+	FdbId   int
+	IfIndex int64
+	Port    string
+}
+
+/*
+* BGP邻居，Uptime为进入established状态的时间(秒)
+ */
+type NetBGPPeer struct {
+	LocalAddr  string
+	LocalAs    int64
+	RemoteAddr string
+	RemoteAs   int64
+	RemoteID   string
+	State      string
+	Uptime     int64
+}
+
+/*
+* 接口上配置的IP地址
+ */
+type NetAddress struct {
+	IfIndex   int64
+	Port      string
+	IP        string
+	PrefixLen int
+}
+
+/*
+* IGP邻居，Protocol为ospf或isis，OSPF使用Area，IS-IS使用Level。
+* NeighborID为OSPF的router id或IS-IS的system id
+ */
+type NetIGPAdj struct {
+	Protocol     string
+	LocalPort    string
+	NeighborAddr string
+	NeighborID   string
+	Area         string
+	Level        string
+	Metric       int64
+	State        string
+}
+
+/*
+* 路由表项，Prefix为网段地址，直连路由的NextHop为空
+ */
+type NetRoute struct {
+	Prefix  string
+	NextHop string
+	IfIndex int64
+	Port    string
+	Type    string
+	Proto   string
+	Metric  int64
+}
+
+/*
+* 接口计数器采样
+ */
+type NetCounters struct {
+	Time        time.Time
+	InOctets    uint64
+	OutOctets   uint64
+	InErrors    uint64
+	OutErrors   uint64
+	InDiscards  uint64
+	OutDiscards uint64
+}
+
+/*
+* 光模块DOM读数，光功率单位为dBm，温度为摄氏度，偏置电流为mA。没有读到的值为nil
+ */
+type NetOptics struct {
+	TxPower     *float64
+	RxPower     *float64
+	Temperature *float64
+	Bias        *float64
+}
@@ -1,5 +1,7 @@
 package util
 
+import "strings"
+
 type NetNode struct {
 	Id         int64
 	Level      float64
@@ -13,6 +15,50 @@ type NetNode struct {
 	Pod        string
 	Name       string
 	Lables     []string
+	Credential string // 扫描时验证通过的认证信息
+	Interfaces map[int64]*NetInterface
+	Ports      map[string]int64 // 端口名称到ifIndex的映射
+	Lags       map[int64]*NetLag
+	Modules    []*NetModule
+	Endpoints  []*NetEndpoint
+	Arp        map[string][]string // MAC地址到IP地址的映射
+	BGPPeers   []*NetBGPPeer
+	IGPAdjs    []*NetIGPAdj
+	Addresses  []*NetAddress
+	Routes     []*NetRoute
+
+	// 从设备上读取的信息以及与资产信息不一致的项
+	Profile       string
+	SysDescr      string
+	SysObjectID   string
+	SysName       string
+	SysUpTime     int64 // 百分之一秒
+	SysLocation   string
+	SysVendor     string
+	Discrepancies []string
+	OSPFRouterID  string
+	ISISSysID     string
+}
+
+/*
+* 角色或标签在列表中
+ */
+func (node *NetNode) MatchRole(roles []string) bool {
+	for _, role := range roles {
+		if strings.EqualFold(node.Role, role) || node.HasLable(role) {
+			return true
+		}
+	}
+	return false
+}
+
+func (node *NetNode) HasLable(lable string) bool {
+	for _, l := range node.Lables {
+		if l == lable {
+			return true
+		}
+	}
+	return false
 }
 
 //对于没法将nodeid转换为int64的，在GLOBAL_CONUTER中选择一个数